@@ -0,0 +1,96 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "compress/bzip2"
+  "compress/flate"
+  "compress/gzip"
+  "compress/zlib"
+  "io"
+  "io/ioutil"
+)
+
+// sniffSize is the number of bytes inspected to detect compressed input
+const sniffSize = 512
+
+// decompress returns a reader with the decompressed contents of r when it
+// starts with the magic bytes of gzip, bzip2 or zlib. Otherwise it returns
+// a reader with the contents of r unchanged. It also reports whether the
+// input is compressed
+func decompress(r io.Reader) (io.Reader, bool, error) {
+  br := bufio.NewReader(r)
+
+  // Short inputs are fine, they just can't be compressed
+  magic, err := br.Peek(sniffSize)
+  if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+    return nil, false, err
+  }
+
+  // The whole input fits in magic unless the buffer filled up
+  whole := err != bufio.ErrBufferFull
+
+  var dr io.Reader
+  err = nil
+
+  switch {
+  case isGzip(magic):
+    dr, err = gzip.NewReader(br)
+  case isBzip2(magic):
+    dr = bzip2.NewReader(br)
+  case isZlib(magic, whole):
+    dr, err = zlib.NewReader(br)
+  default:
+    return br, false, nil
+  }
+
+  if err != nil {
+    return nil, true, err
+  }
+
+  return dr, true, nil
+}
+
+// isGzip checks for the gzip magic bytes followed by the deflate method
+func isGzip(magic []byte) bool {
+  return len(magic) >= 3 &&
+    magic[0] == 0x1f && magic[1] == 0x8b && magic[2] == 8
+}
+
+// isBzip2 checks for the "BZh" magic followed by the block size
+func isBzip2(magic []byte) bool {
+  return len(magic) >= 4 && bytes.HasPrefix(magic, []byte("BZh")) &&
+    magic[3] >= '1' && magic[3] <= '9'
+}
+
+// isZlib checks for a valid zlib header. Since the header is only two
+// bytes long, plain text could match it by chance so the start of the
+// stream must also decode without errors. When magic is the whole input,
+// the stream must decode completely
+func isZlib(magic []byte, whole bool) bool {
+  if len(magic) < 2 {
+    return false
+  }
+
+  // Deflate method, no preset dictionary and a valid check value
+  if magic[0]&0x0f != 8 || magic[0]>>4 > 7 || magic[1]&0x20 != 0 ||
+    (int(magic[0])<<8|int(magic[1]))%31 != 0 {
+    return false
+  }
+
+  if whole {
+    zr, err := zlib.NewReader(bytes.NewReader(magic))
+    if err != nil {
+      return false
+    }
+
+    _, err = io.Copy(ioutil.Discard, zr)
+    return err == nil
+  }
+
+  fr := flate.NewReader(bytes.NewReader(magic[2:]))
+  _, err := io.Copy(ioutil.Discard, fr)
+
+  // The sniffed bytes are only the start of the stream
+  return err == nil || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+  "bytes"
+  "compress/gzip"
+  "compress/zlib"
+  "io/ioutil"
+  "os"
+  "testing"
+)
+
+// TestDecompress tests the detection of compressed input
+func TestDecompress(t *testing.T) {
+  text := "word1 word2 word3\nline2\nline3 word1\n"
+
+  var gz, zl bytes.Buffer
+
+  gw := gzip.NewWriter(&gz)
+  gw.Write([]byte(text))
+  gw.Close()
+
+  zw := zlib.NewWriter(&zl)
+  zw.Write([]byte(text))
+  zw.Close()
+
+  bz, err := ioutil.ReadFile("testdata/one.txt.bz2")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  testCases := []struct {
+    name       string
+    input      []byte
+    exp        string
+    compressed bool
+  }{
+    {name: "Gzip", input: gz.Bytes(), exp: text, compressed: true},
+    {name: "Zlib", input: zl.Bytes(), exp: text, compressed: true},
+    {name: "Bzip2", input: bz, exp: text, compressed: true},
+    {name: "Plain", input: []byte(text), exp: text},
+    // "x^" is also a valid zlib header
+    {name: "PlainZlibHeader", input: []byte("x^2 + y^2\n"),
+      exp: "x^2 + y^2\n"},
+    // "H\r" is a valid zlib header followed by a stream ending early
+    {name: "PlainZlibHeaderShort", input: []byte("H\r\nello\r\n"),
+      exp: "H\r\nello\r\n"},
+    {name: "Empty", input: []byte{}, exp: ""},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      r, compressed, err := decompress(bytes.NewReader(tc.input))
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if compressed != tc.compressed {
+        t.Errorf("Expected compressed %t, got %t instead",
+          tc.compressed, compressed)
+      }
+
+      out, err := ioutil.ReadAll(r)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if string(out) != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, string(out))
+      }
+    })
+  }
+}
+
+// TestRunCompressed tests counting compressed files and Standard Input
+func TestRunCompressed(t *testing.T) {
+  gz, err := os.Open("testdata/two.txt.gz")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer gz.Close()
+
+  testCases := []struct {
+    name  string
+    raw   bool
+    files []string
+    exp   string
+  }{
+    {name: "Files", files: []string{"testdata/one.txt.bz2",
+      "testdata/two.txt.gz"},
+      exp: "  36 testdata/one.txt.bz2\n1320 testdata/two.txt.gz\n" +
+        "1356 total\n"},
+    {name: "RawFiles", raw: true, files: []string{"testdata/one.txt.bz2",
+      "testdata/two.txt.gz"},
+      exp: " 64 testdata/one.txt.bz2\n 75 testdata/two.txt.gz\n" +
+        "139 total\n"},
+    {name: "Stdin", files: []string{}, exp: "1320\n"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out bytes.Buffer
+
+      c := config{cols: columns{bytes: true}, raw: tc.raw,
+        format: "text", wErr: &out}
+
+      if err := run(tc.files, gz, &out, c); err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "io/ioutil"
+)
+
+// readFileList reads the names of the files to count from fname, or from
+// stdin when fname is "-". Names are separated by NUL bytes, as printed by
+// find -print0, or by new lines when there are none. Empty names are
+// ignored
+func readFileList(fname string, stdin io.Reader) ([]string, error) {
+  var data []byte
+  var err error
+
+  if fname == "-" {
+    data, err = ioutil.ReadAll(stdin)
+  } else {
+    data, err = ioutil.ReadFile(fname)
+  }
+
+  if err != nil {
+    return nil, fmt.Errorf("Cannot read file list: %s", err)
+  }
+
+  return splitFileList(data), nil
+}
+
+// splitFileList splits data on NUL bytes, or on new lines without them
+func splitFileList(data []byte) []string {
+  sep := []byte{0}
+  if bytes.IndexByte(data, 0) < 0 {
+    sep = []byte("\n")
+  }
+
+  names := []string{}
+  for _, name := range bytes.Split(data, sep) {
+    if len(name) > 0 {
+      names = append(names, string(name))
+    }
+  }
+
+  return names
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+  "bytes"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// TestSplitFileList tests both separators of file names
+func TestSplitFileList(t *testing.T) {
+  testCases := []struct {
+    name string
+    data string
+    exp  []string
+  }{
+    {name: "NUL", data: "a.txt\x00dir/b c.txt\x00", exp: []string{"a.txt",
+      "dir/b c.txt"}},
+    {name: "NewLines", data: "a.txt\n\nb.txt", exp: []string{"a.txt",
+      "b.txt"}},
+    {name: "NewLineInName", data: "a\nb.txt\x00c.txt",
+      exp: []string{"a\nb.txt", "c.txt"}},
+    {name: "Empty", data: "", exp: []string{}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      res := splitFileList([]byte(tc.data))
+
+      if !reflect.DeepEqual(res, tc.exp) {
+        t.Errorf("Expected %q, got %q instead", tc.exp, res)
+      }
+    })
+  }
+}
+
+// TestRunFilesFrom tests counting the files named in a list
+func TestRunFilesFrom(t *testing.T) {
+  testCases := []struct {
+    name      string
+    filesFrom string
+    files     []string
+    in        string
+    exp       string
+    errMsg    string
+  }{
+    {name: "Stdin", filesFrom: "-",
+      in:  "testdata/one.txt\x00testdata/two.txt\x00",
+      exp: " 3 testdata/one.txt\n30 testdata/two.txt\n33 total\n"},
+    {name: "File", filesFrom: "testdata/files.txt",
+      exp: " 3 testdata/one.txt\n30 testdata/two.txt.gz\n33 total\n"},
+    {name: "EmptyList", filesFrom: "-", exp: ""},
+    {name: "FailArgs", filesFrom: "testdata/files.txt",
+      files:  []string{"testdata/one.txt"},
+      errMsg: "File names can't be combined with -files-from"},
+    {name: "FailMissingList", filesFrom: "testdata/fakefile.txt",
+      errMsg: "Cannot read file list"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+
+      c := config{cols: columns{lines: true}, workers: 2, wErr: &errOut,
+        format: "text", filesFrom: tc.filesFrom}
+
+      err := run(tc.files, strings.NewReader(tc.in), &out, c)
+
+      if tc.errMsg != "" {
+        if err == nil {
+          t.Fatalf("Expected error. Got nil instead")
+        }
+
+        if !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Unexpected error message: %q", err)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
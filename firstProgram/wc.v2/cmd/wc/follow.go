@@ -0,0 +1,96 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "time"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// follow counts fname and then keeps counting only the bytes appended to
+// it, printing the counters every cfg.interval, like tail -f. When the
+// file is truncated or replaced by a new one, as when logs are rotated,
+// counting starts again from the beginning. It prints the final counters
+// and returns when done is closed
+func follow(fname string, out io.Writer, cfg config,
+  done <-chan struct{}) error {
+
+  f, err := os.Open(fname)
+  if err != nil {
+    return fmt.Errorf("Cannot open file: %s", err)
+  }
+  defer func() {
+    f.Close()
+  }()
+
+  c := wc.NewCounter(wc.Options{Tokenizer: cfg.tokenizer})
+
+  // update reads whatever was appended since the last call and prints
+  // the counters so far
+  update := func() error {
+    if _, err := c.ReadFrom(f); err != nil {
+      return fmt.Errorf("Cannot read file: %s", err)
+    }
+
+    fr := fileResult{name: fname, res: c.Result()}
+    rep := report{rows: []fileResult{fr}, total: fr.res}
+
+    return rep.print(out, cfg.cols, cfg.format)
+  }
+
+  if err := update(); err != nil {
+    return err
+  }
+
+  ticker := time.NewTicker(cfg.interval)
+  defer ticker.Stop()
+
+  for {
+    select {
+    case <-done:
+      return update()
+    case <-ticker.C:
+    }
+
+    cur, err := f.Stat()
+    if err != nil {
+      return fmt.Errorf("Cannot read file: %s", err)
+    }
+
+    offset, err := f.Seek(0, io.SeekCurrent)
+    if err != nil {
+      return fmt.Errorf("Cannot read file: %s", err)
+    }
+
+    // The file may be missing for a moment while it's rotated. Keep
+    // counting the old one until the new one shows up
+    info, err := os.Stat(fname)
+
+    switch {
+    case err == nil && !os.SameFile(cur, info):
+      fmt.Fprintf(cfg.wErr, "%s: file replaced, counting again\n", fname)
+
+      nf, err := os.Open(fname)
+      if err != nil {
+        return fmt.Errorf("Cannot open file: %s", err)
+      }
+
+      f.Close()
+      f = nf
+      c = wc.NewCounter(wc.Options{Tokenizer: cfg.tokenizer})
+    case cur.Size() < offset:
+      fmt.Fprintf(cfg.wErr, "%s: file truncated, counting again\n", fname)
+
+      if _, err := f.Seek(0, io.SeekStart); err != nil {
+        return fmt.Errorf("Cannot read file: %s", err)
+      }
+      c = wc.NewCounter(wc.Options{Tokenizer: cfg.tokenizer})
+    }
+
+    if err := update(); err != nil {
+      return err
+    }
+  }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+// syncBuffer is a bytes.Buffer safe to use from several goroutines
+type syncBuffer struct {
+  mu  sync.Mutex
+  buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+  b.mu.Lock()
+  defer b.mu.Unlock()
+  return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+  b.mu.Lock()
+  defer b.mu.Unlock()
+  return b.buf.String()
+}
+
+// waitFor waits until the output ends with suffix
+func waitFor(t *testing.T, b *syncBuffer, suffix string) {
+  t.Helper()
+
+  deadline := time.Now().Add(5 * time.Second)
+  for !strings.HasSuffix(b.String(), suffix) {
+    if time.Now().After(deadline) {
+      t.Fatalf("Expected output ending with %q, got %q instead", suffix,
+        b.String())
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+}
+
+// appendFile appends data to the file fname
+func appendFile(t *testing.T, fname, data string) {
+  t.Helper()
+
+  f, err := os.OpenFile(fname, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+
+  if _, err := f.WriteString(data); err != nil {
+    t.Fatal(err)
+  }
+}
+
+// TestFollow tests counting a file as it grows, is truncated and rotated
+func TestFollow(t *testing.T) {
+  tempDir, err := ioutil.TempDir("", "wcfollow")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(tempDir)
+
+  fname := filepath.Join(tempDir, "app.log")
+  appendFile(t, fname, "one two\n")
+
+  var out, errOut syncBuffer
+  done := make(chan struct{})
+  errCh := make(chan error)
+
+  c := config{
+    cols:     columns{lines: true},
+    format:   "text",
+    interval: 10 * time.Millisecond,
+    wErr:     &errOut,
+  }
+
+  go func() {
+    errCh <- follow(fname, &out, c, done)
+  }()
+
+  row := func(lines int) string {
+    return fmt.Sprintf("%d %s\n", lines, fname)
+  }
+
+  waitFor(t, &out, row(1))
+
+  // Only the appended lines are read
+  appendFile(t, fname, "three\nfour\n")
+  waitFor(t, &out, row(3))
+
+  // The file must be seen empty before writing to it again
+  if err := os.Truncate(fname, 0); err != nil {
+    t.Fatal(err)
+  }
+  waitFor(t, &errOut, "file truncated, counting again\n")
+
+  appendFile(t, fname, "x\n")
+  waitFor(t, &out, row(1))
+
+  // Rotating creates a new file with the same name
+  if err := os.Rename(fname, fname+".1"); err != nil {
+    t.Fatal(err)
+  }
+  appendFile(t, fname, "a\nb\n")
+  waitFor(t, &errOut, "file replaced, counting again\n")
+  waitFor(t, &out, row(2))
+
+  close(done)
+
+  if err := <-errCh; err != nil {
+    t.Fatal(err)
+  }
+}
+
+// TestRunFollowArgs tests that follow mode requires a single file
+func TestRunFollowArgs(t *testing.T) {
+  var out bytes.Buffer
+
+  c := config{follow: true, format: "text", interval: time.Second}
+
+  err := run([]string{"testdata/one.txt", "testdata/two.txt"}, nil, &out, c)
+  if err == nil {
+    t.Fatal("Expected error. Got nil instead")
+  }
+
+  if !strings.Contains(err.Error(), "Follow mode requires a single file") {
+    t.Errorf("Unexpected error message: %q", err)
+  }
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+  "bufio"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// freqOptions defines how words are normalized before being counted
+type freqOptions struct {
+  // fold words to lower case
+  fold bool
+  // strip punctuation around words
+  strip bool
+  // words left out of the table
+  stopwords map[string]bool
+  // splits lines into words
+  tok wc.Tokenizer
+}
+
+// wordCount is a word and the number of times it was found
+type wordCount struct {
+  Word  string `json:"word"`
+  Count int    `json:"count"`
+}
+
+// runFreq builds a single frequency table from all files, or from in when
+// it's not nil, and prints the top cfg.freq words
+func runFreq(filenames []string, in io.Reader, out io.Writer,
+  cfg config) error {
+
+  opts := freqOptions{fold: cfg.fold, strip: cfg.strip, tok: cfg.tokenizer}
+
+  if cfg.stopwords != "" {
+    f, err := os.Open(cfg.stopwords)
+    if err != nil {
+      return fmt.Errorf("Cannot open stopwords file: %s", err)
+    }
+    defer f.Close()
+
+    if opts.stopwords, err = loadStopwords(f, opts); err != nil {
+      return fmt.Errorf("Cannot read stopwords file: %s", err)
+    }
+  }
+
+  freq := map[string]int{}
+
+  if in != nil {
+    if err := freqReader(in, cfg, opts, freq); err != nil {
+      return fmt.Errorf("Cannot read input: %s", err)
+    }
+
+    return printFreq(out, topWords(freq, cfg.freq), cfg.format)
+  }
+
+  failed := 0
+  for _, fname := range filenames {
+    if err := freqFile(fname, cfg, opts, freq); err != nil {
+      fmt.Fprintln(cfg.wErr, err)
+      failed++
+    }
+  }
+
+  if err := printFreq(out, topWords(freq, cfg.freq), cfg.format); err != nil {
+    return err
+  }
+
+  if failed > 0 {
+    return fmt.Errorf("Cannot count %d of %d files", failed, len(filenames))
+  }
+
+  return nil
+}
+
+// freqFile adds the words of a single file to freq. Binary files are
+// skipped when cfg.skipBinary is set
+func freqFile(fname string, cfg config, opts freqOptions,
+  freq map[string]int) error {
+
+  f, err := os.Open(fname)
+  if err != nil {
+    return fmt.Errorf("Cannot open file: %s", err)
+  }
+  defer f.Close()
+
+  r, _, binary, err := inputReader(f, cfg, nil)
+  if err != nil {
+    return fmt.Errorf("Cannot read file: %s", err)
+  }
+
+  if binary {
+    return nil
+  }
+
+  if err := frequencies(r, opts, freq); err != nil {
+    return fmt.Errorf("Cannot read file: %s", err)
+  }
+
+  return nil
+}
+
+// freqReader adds the words of r to freq, decompressing and decoding it
+// first
+func freqReader(r io.Reader, cfg config, opts freqOptions,
+  freq map[string]int) error {
+
+  r, _, _, err := inputReader(r, cfg, nil)
+  if err != nil {
+    return err
+  }
+
+  return frequencies(r, opts, freq)
+}
+
+// frequencies adds every word read from r to freq. Lines are split into
+// words by opts.tok, or on white space when it's not set
+func frequencies(r io.Reader, opts freqOptions, freq map[string]int) error {
+  split := strings.Fields
+  if opts.tok != nil {
+    split = opts.tok.Split
+  }
+
+  // A buffered reader has no limit on the length of lines
+  br := bufio.NewReader(r)
+
+  for {
+    line, err := br.ReadString('\n')
+    if err != nil && err != io.EOF {
+      return err
+    }
+
+    for _, w := range split(line) {
+      w = opts.normalize(w)
+      if w == "" || opts.stopwords[w] {
+        continue
+      }
+
+      freq[w]++
+    }
+
+    if err == io.EOF {
+      return nil
+    }
+  }
+}
+
+// normalize applies the options to word. It returns an empty string
+// when nothing is left of it
+func (o freqOptions) normalize(word string) string {
+  if o.strip {
+    word = strings.TrimFunc(word, unicode.IsPunct)
+  }
+
+  if o.fold {
+    word = strings.ToLower(word)
+  }
+
+  return word
+}
+
+// loadStopwords reads a list of words separated by spaces or new lines,
+// normalized the same way as the counted words
+func loadStopwords(r io.Reader, opts freqOptions) (map[string]bool, error) {
+  stopwords := map[string]bool{}
+
+  scanner := bufio.NewScanner(r)
+  scanner.Split(bufio.ScanWords)
+
+  for scanner.Scan() {
+    if w := opts.normalize(scanner.Text()); w != "" {
+      stopwords[w] = true
+    }
+  }
+
+  return stopwords, scanner.Err()
+}
+
+// topWords returns the n most frequent words. Words with the same count
+// are sorted alphabetically so the output is stable
+func topWords(freq map[string]int, n int) []wordCount {
+  words := make([]wordCount, 0, len(freq))
+  for w, c := range freq {
+    words = append(words, wordCount{Word: w, Count: c})
+  }
+
+  sort.Slice(words, func(i, j int) bool {
+    if words[i].Count != words[j].Count {
+      return words[i].Count > words[j].Count
+    }
+    return words[i].Word < words[j].Word
+  })
+
+  if n < len(words) {
+    words = words[:n]
+  }
+
+  return words
+}
+
+// printFreq prints the words as text, one per line preceded by the count,
+// as a JSON array or as CSV records
+func printFreq(out io.Writer, words []wordCount, format string) error {
+  switch format {
+  case "json":
+    enc := json.NewEncoder(out)
+    enc.SetIndent("", "  ")
+    return enc.Encode(words)
+  case "csv":
+    w := csv.NewWriter(out)
+    w.Write([]string{"word", "count"})
+    for _, wc := range words {
+      w.Write([]string{wc.Word, strconv.Itoa(wc.Count)})
+    }
+    w.Flush()
+    return w.Error()
+  }
+
+  width := 0
+  if len(words) > 0 {
+    width = len(strconv.Itoa(words[0].Count))
+  }
+
+  for _, wc := range words {
+    if _, err := fmt.Fprintf(out, "%*d %s\n", width, wc.Count,
+      wc.Word); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
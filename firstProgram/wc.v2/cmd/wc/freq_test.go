@@ -0,0 +1,104 @@
+package main
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+// TestRunFreq tests the word frequency report with different options
+func TestRunFreq(t *testing.T) {
+  testCases := []struct {
+    name      string
+    freq      int
+    fold      bool
+    strip     bool
+    stopwords string
+    format    string
+    files     []string
+    exp       string
+    errMsg    string
+  }{
+    {name: "Plain", freq: 3, format: "text",
+      files: []string{"testdata/freq.txt"},
+      exp:   "3 the\n1 A\n1 END!\n"},
+    {name: "FoldStrip", freq: 3, fold: true, strip: true, format: "text",
+      files: []string{"testdata/freq.txt"},
+      exp:   "5 the\n2 a\n2 cat\n"},
+    {name: "Stopwords", freq: 10, fold: true, strip: true,
+      stopwords: "testdata/stopwords.txt", format: "text",
+      files: []string{"testdata/freq.txt"},
+      exp:   "2 cat\n2 dog\n1 end\n"},
+    {name: "MultiFilesJSON", freq: 2, fold: true, strip: true,
+      format: "json",
+      files:  []string{"testdata/freq.txt", "testdata/one.txt"},
+      exp: "[\n  {\n    \"word\": \"the\",\n    \"count\": 5\n  },\n" +
+        "  {\n    \"word\": \"a\",\n    \"count\": 2\n  }\n]\n"},
+    {name: "Stdin", freq: 1, format: "text", files: []string{},
+      exp: "2 word\n"},
+    {name: "FailFormat", freq: 1, format: "xml",
+      files:  []string{"testdata/freq.txt"},
+      errMsg: "Output format not supported: xml"},
+    {name: "FailStopwords", freq: 1, format: "text",
+      stopwords: "testdata/fakefile.txt",
+      files:     []string{"testdata/freq.txt"},
+      errMsg:    "Cannot open stopwords file"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+      in := strings.NewReader("word other word\n")
+
+      c := config{
+        freq:      tc.freq,
+        fold:      tc.fold,
+        strip:     tc.strip,
+        stopwords: tc.stopwords,
+        format:    tc.format,
+        wErr:      &errOut,
+      }
+
+      err := run(tc.files, in, &out, c)
+
+      if tc.errMsg != "" {
+        if err == nil {
+          t.Fatalf("Expected error. Got nil instead")
+        }
+
+        if !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Unexpected error message: %q", err)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatalf("Unexpected error: %q", err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
+
+// TestTopWords tests that ties are sorted alphabetically
+func TestTopWords(t *testing.T) {
+  freq := map[string]int{"b": 2, "a": 2, "c": 5, "d": 1}
+
+  exp := []wordCount{{"c", 5}, {"a", 2}, {"b", 2}}
+
+  res := topWords(freq, 3)
+
+  if len(res) != len(exp) {
+    t.Fatalf("Expected %d words, got %d instead", len(exp), len(res))
+  }
+
+  for i := range exp {
+    if res[i] != exp[i] {
+      t.Errorf("Expected %v, got %v instead", exp[i], res[i])
+    }
+  }
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "os/signal"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "sync"
+  "syscall"
+  "time"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// minChunk is the smallest range of a file worth counting in its own
+// goroutine
+const minChunk = 4 * 1024 * 1024
+
+type config struct {
+  // counters to print
+  cols columns
+  // number of files counted at the same time
+  workers int
+  // number of ranges of a large file counted at the same time
+  jobs int
+  // count compressed input as is instead of decompressing it
+  raw bool
+  // encoding of the input, converted to UTF-8 before counting
+  encoding string
+  // file with the names of the files to count, "-" for stdin
+  filesFrom string
+  // walk directories recursively
+  recursive bool
+  // glob patterns of file names to include or exclude when walking
+  include []string
+  exclude []string
+  // skip files that look like binary data
+  skipBinary bool
+  // classify lines as code, comment or blank
+  sloc bool
+  // report line length statistics instead of counting
+  stats bool
+  // keep counting the bytes appended to the file
+  follow bool
+  // copy the input to the output and print the counters to wErr
+  tee bool
+  // print the progress every interval in tee mode
+  progress bool
+  // time between reports in follow and tee modes
+  interval time.Duration
+  // splits words instead of white space when set
+  tokenizer wc.Tokenizer
+  // destination for errors on individual files and the counters in tee
+  // mode
+  wErr io.Writer
+  // number of most frequent words to report, 0 to count normally
+  freq int
+  // fold words to lower case in frequency mode
+  fold bool
+  // strip punctuation around words in frequency mode
+  strip bool
+  // file with words left out of the frequency table
+  stopwords string
+  // output format
+  format string
+}
+
+// fileResult holds the counters of a single file or the error that
+// prevented it from being counted
+type fileResult struct {
+  name string
+  res  wc.Result
+  err  error
+  // the file was skipped because it looks like binary data
+  skipped bool
+  // the counters are the subtotal of the directory name
+  subtotal bool
+  // language and lines by kind, in sloc mode
+  lang string
+  sloc slocResult
+  // lines by length, in stats mode
+  lengths lineLengths
+}
+
+func main() {
+  // Defining a boolean flag -l to count lines
+  lines := flag.Bool("l", false, "Count lines")
+  // Defining a boolean flag -w to count words
+  words := flag.Bool("w", false, "Count words")
+  // Defining a boolean flag -b to count bytes
+  bytes := flag.Bool("b", false, "Count bytes")
+  // Defining a boolean flag -m to count characters (runes)
+  runes := flag.Bool("m", false, "Count characters")
+  // Defining a boolean flag -L to print the length of the longest line
+  maxLine := flag.Bool("L", false, "Print the length of the longest line")
+  // Defining a boolean flag -invalid to count bytes that aren't UTF-8
+  invalid := flag.Bool("invalid", false,
+    "Count bytes that are not valid UTF-8, left out of characters")
+  // Defining an int flag -j to count large files in parallel ranges
+  jobs := flag.Int("j", 1, "Number of goroutines counting each large file")
+  // Defining a boolean flag -raw to skip decompression
+  raw := flag.Bool("raw", false, "Count compressed input without decompressing")
+  // Defining a string flag -encoding to convert the input to UTF-8
+  encoding := flag.String("encoding", "auto",
+    "Input encoding: auto, utf-8, utf-16le, utf-16be or latin1")
+  // Defining a string flag -files-from to read file names from a file
+  filesFrom := flag.String("files-from", "",
+    "Count the NUL or new line separated file names read from a file, - for Standard Input")
+  // Recursive mode options
+  recursive := flag.Bool("r", false, "Count files in directories recursively")
+  include := flag.String("include", "",
+    "Comma separated glob patterns of file names to count with -r")
+  exclude := flag.String("exclude", "",
+    "Comma separated glob patterns of file or directory names to skip with -r")
+  binary := flag.Bool("binary", false, "Count binary files found with -r")
+  // Defining a boolean flag -sloc to classify lines of source code
+  sloc := flag.Bool("sloc", false,
+    "Count code, comment and blank lines by language")
+  // Defining a boolean flag -stats to report line length statistics
+  stats := flag.Bool("stats", false,
+    "Report line length statistics and a histogram")
+  // Word splitting options
+  tokName := flag.String("tokenizer", "whitespace",
+    "Split words by: whitespace, regex, unicode or ident")
+  sep := flag.String("sep", "",
+    "Separator regular expression for -tokenizer regex")
+  // Follow mode options
+  follow := flag.Bool("f", false, "Keep counting the file as it grows")
+  interval := flag.Duration("interval", time.Second,
+    "Time between reports with -f, or progress reports with -tee")
+  // Defining a boolean flag -tee to measure data passing through a pipeline
+  teeMode := flag.Bool("tee", false,
+    "Copy the Standard Input to the Standard Output and print the counters to the Standard Error")
+  // Frequency mode options
+  freq := flag.Int("freq", 0, "Report the N most frequent words")
+  fold := flag.Bool("fold", false, "Fold words to lower case with -freq")
+  strip := flag.Bool("strip", false,
+    "Strip punctuation around words with -freq")
+  stopwords := flag.String("stopwords", "",
+    "File with words to ignore with -freq")
+  // Output format
+  format := flag.String("o", "text", "Output format: text, json or csv")
+  // Parsing the flags provided by the user
+  flag.Parse()
+
+  cols := columns{
+    lines:   *lines,
+    words:   *words,
+    runes:   *runes,
+    bytes:   *bytes,
+    maxLine: *maxLine,
+    invalid: *invalid,
+  }
+
+  // Without any flags we keep the original behavior of counting words
+  if !cols.any() {
+    cols.words = true
+  }
+
+  // Progress is only printed in tee mode when asked for an interval
+  progress := false
+  flag.Visit(func(f *flag.Flag) {
+    if f.Name == "interval" {
+      progress = *teeMode
+    }
+  })
+
+  tok, err := wc.NewTokenizer(*tokName, *sep)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+
+  c := config{
+    cols:       cols,
+    workers:    runtime.NumCPU(),
+    jobs:       *jobs,
+    raw:        *raw,
+    encoding:   *encoding,
+    filesFrom:  *filesFrom,
+    recursive:  *recursive,
+    include:    splitPatterns(*include),
+    exclude:    splitPatterns(*exclude),
+    skipBinary: *recursive && !*binary,
+    sloc:       *sloc,
+    stats:      *stats,
+    follow:     *follow,
+    tee:        *teeMode,
+    progress:   progress,
+    interval:   *interval,
+    tokenizer:  tok,
+    wErr:       os.Stderr,
+    freq:       *freq,
+    fold:       *fold,
+    strip:      *strip,
+    stopwords:  *stopwords,
+    format:     *format,
+  }
+
+  // Any arguments left after the flags are file names. Without them
+  // we count what is received from the Standard Input
+  if err := run(flag.Args(), os.Stdin, os.Stdout, c); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+func run(filenames []string, in io.Reader, out io.Writer, cfg config) error {
+  switch cfg.format {
+  case "text", "json", "csv":
+  default:
+    return fmt.Errorf("Output format not supported: %s", cfg.format)
+  }
+
+  switch cfg.encoding {
+  case "", "auto", "utf-8", "utf-16le", "utf-16be", "latin1":
+  default:
+    return fmt.Errorf("Encoding not supported: %s", cfg.encoding)
+  }
+
+  for _, p := range append(cfg.include, cfg.exclude...) {
+    if _, err := filepath.Match(p, ""); err != nil {
+      return fmt.Errorf("Invalid pattern %q: %s", p, err)
+    }
+  }
+
+  if cfg.follow {
+    if len(filenames) != 1 {
+      return fmt.Errorf("Follow mode requires a single file")
+    }
+
+    if cfg.interval <= 0 {
+      return fmt.Errorf("Invalid interval: %s", cfg.interval)
+    }
+
+    // Follow the file until the user interrupts the program
+    sig := make(chan os.Signal, 1)
+    signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+    defer signal.Stop(sig)
+
+    done := make(chan struct{})
+    go func() {
+      <-sig
+      close(done)
+    }()
+
+    return follow(filenames[0], out, cfg, done)
+  }
+
+  if cfg.tee {
+    if len(filenames) > 0 || cfg.filesFrom != "" || cfg.recursive ||
+      cfg.freq > 0 || cfg.sloc || cfg.stats {
+      return fmt.Errorf("Tee mode counts the Standard Input only")
+    }
+
+    if cfg.progress && cfg.interval <= 0 {
+      return fmt.Errorf("Invalid interval: %s", cfg.interval)
+    }
+
+    return tee(in, out, cfg)
+  }
+
+  if cfg.filesFrom != "" {
+    if len(filenames) > 0 {
+      return fmt.Errorf("File names can't be combined with -files-from")
+    }
+
+    var err error
+    if filenames, err = readFileList(cfg.filesFrom, in); err != nil {
+      return err
+    }
+  }
+
+  // Without file names we count the Standard Input. Walking empty
+  // directories or an empty file list is not the same
+  stdin := len(filenames) == 0 && cfg.filesFrom == ""
+
+  // Errors found walking directories don't stop the other files
+  failed := 0
+  var roots map[string]string
+
+  if cfg.recursive {
+    var errs []error
+    filenames, roots, errs = expandFiles(filenames, cfg)
+
+    for _, err := range errs {
+      fmt.Fprintln(cfg.wErr, err)
+    }
+    failed = len(errs)
+  }
+
+  inputs := len(filenames) + failed
+
+  if cfg.freq > 0 {
+    if !stdin {
+      in = nil
+    }
+
+    if err := runFreq(filenames, in, out, cfg); err != nil {
+      return err
+    }
+
+    if failed > 0 {
+      return fmt.Errorf("Cannot count %d of %d files", failed, inputs)
+    }
+
+    return nil
+  }
+
+  if stdin {
+    fr := fileResult{}
+    var err error
+
+    switch {
+    case cfg.sloc:
+      fr.lang = otherLang.name
+      fr.sloc, err = slocReader(in, cfg)
+    case cfg.stats:
+      fr.lengths, err = lengthsReader(in, cfg)
+    default:
+      fr.res, err = countReader(in, cfg)
+    }
+
+    if err != nil {
+      return fmt.Errorf("Cannot read input: %s", err)
+    }
+
+    switch {
+    case cfg.sloc:
+      return printSloc(out, []fileResult{fr}, false, cfg.format)
+    case cfg.stats:
+      return printStats(out, []fileResult{fr}, false, cfg.format)
+    }
+
+    rep := report{rows: []fileResult{fr}, total: fr.res}
+    return rep.print(out, cfg.cols, cfg.format)
+  }
+
+  results := countFiles(filenames, cfg)
+
+  rep := report{showTotal: len(filenames) > 1}
+
+  // Results are kept in the same order as the arguments
+  for _, fr := range results {
+    if fr.err != nil {
+      fmt.Fprintln(cfg.wErr, fr.err)
+      failed++
+      continue
+    }
+
+    if fr.skipped {
+      continue
+    }
+
+    rep.rows = append(rep.rows, fr)
+    rep.total.Add(fr.res)
+  }
+
+  var err error
+
+  switch {
+  case cfg.sloc:
+    // Lines are grouped by language instead of directory
+    err = printSloc(out, rep.rows, cfg.recursive || len(filenames) > 1,
+      cfg.format)
+  case cfg.stats:
+    err = printStats(out, rep.rows, cfg.recursive || len(filenames) > 1,
+      cfg.format)
+  case cfg.recursive:
+    rep.rows = addSubtotals(rep.rows, roots)
+    fallthrough
+  default:
+    err = rep.print(out, cfg.cols, cfg.format)
+  }
+
+  if err != nil {
+    return err
+  }
+
+  if failed > 0 {
+    return fmt.Errorf("Cannot count %d of %d files", failed, inputs)
+  }
+
+  return nil
+}
+
+// countFiles counts all files using a pool of cfg.workers. The results
+// are returned in the same order as filenames
+func countFiles(filenames []string, cfg config) []fileResult {
+  results := make([]fileResult, len(filenames))
+
+  workers := cfg.workers
+  if workers < 1 {
+    workers = 1
+  }
+
+  // Send the index of every file through the channel so each one
+  // is processed when a worker is available
+  idxCh := make(chan int)
+  go func() {
+    defer close(idxCh)
+    for i := range filenames {
+      idxCh <- i
+    }
+  }()
+
+  wg := sync.WaitGroup{}
+
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      // Each worker writes to its own positions of results only
+      for i := range idxCh {
+        results[i] = countFile(filenames[i], cfg)
+      }
+    }()
+  }
+
+  wg.Wait()
+
+  return results
+}
+
+// countFile opens and counts a single file. Compressed files are counted
+// after decompressing them. Large regular files are split into up to
+// cfg.jobs ranges counted in parallel
+func countFile(fname string, cfg config) fileResult {
+  fr := fileResult{name: fname}
+
+  f, err := os.Open(fname)
+  if err != nil {
+    fr.err = fmt.Errorf("Cannot open file: %s", err)
+    return fr
+  }
+  defer f.Close()
+
+  raw := byteCount(0)
+  r, converted, binary, err := inputReader(f, cfg, &raw)
+  if err != nil {
+    fr.err = fmt.Errorf("Cannot read file: %s", err)
+    return fr
+  }
+
+  if binary {
+    fr.skipped = true
+    return fr
+  }
+
+  if cfg.sloc {
+    lang := languageFor(fname)
+    fr.lang = lang.name
+
+    if fr.sloc, err = countSloc(r, lang); err != nil {
+      fr.err = fmt.Errorf("Cannot read file: %s", err)
+    }
+
+    return fr
+  }
+
+  if cfg.stats {
+    if fr.lengths, err = countLengths(r); err != nil {
+      fr.err = fmt.Errorf("Cannot read file: %s", err)
+    }
+
+    return fr
+  }
+
+  // Large regular files are split into ranges of at least minChunk
+  // bytes. Anything else, including compressed or decoded files, is read
+  // sequentially. So are files with words split by a tokenizer
+  jobs := cfg.jobs
+  if cfg.tokenizer != nil {
+    jobs = 1
+  }
+  size := int64(0)
+  if info, err := f.Stat(); err == nil && info.Mode().IsRegular() &&
+    !converted {
+    size = info.Size()
+  }
+
+  if n := size / minChunk; n < int64(jobs) {
+    jobs = int(n)
+  }
+
+  if jobs > 1 {
+    fr.res, err = wc.CountParallel(f, size, jobs)
+  } else {
+    fr.res, err = wc.Count(r, wc.Options{Tokenizer: cfg.tokenizer})
+    fr.res.Bytes = int(raw)
+  }
+
+  if err != nil {
+    fr.err = fmt.Errorf("Cannot read file: %s", err)
+  }
+
+  return fr
+}
+
+// inputReader returns a reader with the contents of the file r ready to
+// be counted, decompressed unless cfg.raw is set and converted to UTF-8
+// from cfg.encoding. The contents are written to raw, unless it's nil, as
+// they're read before converting them, so bytes are counted as in the
+// file. It also reports whether the contents differ from the bytes of r
+// and whether they must be skipped as binary data
+func inputReader(r io.Reader, cfg config,
+  raw io.Writer) (io.Reader, bool, bool, error) {
+
+  compressed, converted, binary := false, false, false
+  var err error
+
+  if !cfg.raw {
+    if r, compressed, err = decompress(r); err != nil {
+      return nil, false, false, err
+    }
+  }
+
+  if raw != nil {
+    r = io.TeeReader(r, raw)
+  }
+
+  if r, converted, err = wc.Decode(r, cfg.encoding); err != nil {
+    return nil, false, false, err
+  }
+
+  // Binary files are detected after decoding them since UTF-16 text is
+  // full of NUL bytes
+  if cfg.skipBinary {
+    if r, binary, err = sniffBinary(r); err != nil {
+      return nil, false, false, err
+    }
+  }
+
+  return r, compressed || converted, binary, nil
+}
+
+// byteCount counts the bytes written to it
+type byteCount int
+
+func (b *byteCount) Write(p []byte) (int, error) {
+  *b += byteCount(len(p))
+  return len(p), nil
+}
+
+// countReader counts r, decompressing and decoding it first
+func countReader(r io.Reader, cfg config) (wc.Result, error) {
+  raw := byteCount(0)
+  r, _, _, err := inputReader(r, cfg, &raw)
+  if err != nil {
+    return wc.Result{}, err
+  }
+
+  res, err := wc.Count(r, wc.Options{Tokenizer: cfg.tokenizer})
+  res.Bytes = int(raw)
+  return res, err
+}
+
+// slocReader classifies the lines of r, decompressing and decoding it
+// first. The language can't be detected so only blank lines are told
+// apart from code
+func slocReader(r io.Reader, cfg config) (slocResult, error) {
+  r, _, _, err := inputReader(r, cfg, nil)
+  if err != nil {
+    return slocResult{}, err
+  }
+
+  return countSloc(r, otherLang)
+}
+
+// lengthsReader measures the lines of r, decompressing and decoding it
+// first
+func lengthsReader(r io.Reader, cfg config) (lineLengths, error) {
+  r, _, _, err := inputReader(r, cfg, nil)
+  if err != nil {
+    return nil, err
+  }
+
+  return countLengths(r)
+}
+
+// splitPatterns returns the comma separated patterns in s
+func splitPatterns(s string) []string {
+  if s == "" {
+    return nil
+  }
+
+  return strings.Split(s, ",")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+  "bytes"
+  "io/ioutil"
+  "os"
+  "runtime"
+  "strings"
+  "testing"
+)
+
+// TestRun tests counting multiple files and printing them in order
+func TestRun(t *testing.T) {
+  testCases := []struct {
+    name     string
+    cols     columns
+    format   string
+    encoding string
+    files    []string
+    exp      string
+    expErr   string
+    errMsg   string
+  }{
+    {name: "Stdin", cols: columns{words: true}, format: "text",
+      files: []string{},
+      exp:   "4\n"},
+    {name: "SingleFile", cols: columns{lines: true, words: true},
+      format: "text",
+      files:  []string{"testdata/one.txt"},
+      exp:    "3 6 testdata/one.txt\n"},
+    {name: "MultiFiles", cols: columns{lines: true, words: true, bytes: true},
+      format: "text",
+      files:  []string{"testdata/two.txt", "testdata/one.txt"},
+      exp: "  30  270 1320 testdata/two.txt\n" +
+        "   3    6   36 testdata/one.txt\n" +
+        "  33  276 1356 total\n"},
+    {name: "MissingFile", cols: columns{lines: true}, format: "text",
+      files: []string{"testdata/one.txt", "testdata/fakefile.txt",
+        "testdata/two.txt"},
+      exp:    " 3 testdata/one.txt\n30 testdata/two.txt\n33 total\n",
+      expErr: "Cannot open file",
+      errMsg: "Cannot count 1 of 3 files"},
+    {name: "StdinCSV", cols: columns{lines: true, words: true},
+      format: "csv", files: []string{},
+      exp: "file,lines,words\n-,1,4\n"},
+    {name: "MultiFilesCSV", cols: columns{words: true, maxLine: true},
+      format: "csv",
+      files:  []string{"testdata/one.txt", "testdata/two.txt"},
+      exp: "file,words,max_line_length\n" +
+        "testdata/one.txt,6,17\ntestdata/two.txt,270,43\ntotal,276,43\n"},
+    {name: "SingleFileJSON", cols: columns{lines: true, runes: true},
+      format: "json", files: []string{"testdata/one.txt"},
+      exp: `{
+  "files": [
+    {
+      "file": "testdata/one.txt",
+      "lines": 3,
+      "chars": 36
+    }
+  ],
+  "total": {
+    "lines": 3,
+    "chars": 36
+  }
+}
+`},
+    {name: "UTF16BOM", cols: columns{lines: true, words: true, runes: true,
+      bytes: true}, format: "text", files: []string{"testdata/utf16.txt"},
+      exp: " 2  4 24 50 testdata/utf16.txt\n"},
+    {name: "Latin1", cols: columns{words: true, runes: true, bytes: true},
+      format: "text", encoding: "latin1",
+      files: []string{"testdata/latin1.txt"},
+      exp:   " 2 11 11 testdata/latin1.txt\n"},
+    {name: "InvalidCSV", cols: columns{runes: true, bytes: true,
+      invalid: true}, format: "csv", files: []string{"testdata/invalid.txt"},
+      exp: "file,chars,bytes,invalid\ntestdata/invalid.txt,16,19,2\n"},
+    {name: "FailEncoding", cols: columns{words: true}, format: "text",
+      encoding: "ebcdic", files: []string{"testdata/one.txt"},
+      errMsg: "Encoding not supported: ebcdic"},
+    {name: "FailFormat", cols: columns{words: true}, format: "xml",
+      files: []string{"testdata/one.txt"}, expErr: "",
+      errMsg: "Output format not supported: xml"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+      in := bytes.NewBufferString("word1 word2 word3 word4\n")
+
+      c := config{cols: tc.cols, workers: 2, wErr: &errOut,
+        format: tc.format, encoding: tc.encoding}
+      err := run(tc.files, in, &out, c)
+
+      if tc.errMsg != "" {
+        if err == nil {
+          t.Fatalf("Expected error. Got nil instead")
+        }
+
+        if !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Unexpected error message: %q", err)
+        }
+      } else if err != nil {
+        t.Fatalf("Unexpected error: %q", err)
+      }
+
+      if !strings.Contains(errOut.String(), tc.expErr) {
+        t.Errorf("Expected error output %q, got %q instead", tc.expErr,
+          errOut.String())
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
+
+// createBenchFile creates a temporary file with about size bytes of text
+func createBenchFile(b *testing.B, size int) string {
+  b.Helper()
+
+  tf, err := ioutil.TempFile("", "wcbench")
+  if err != nil {
+    b.Fatalf("Error creating temp file: %s", err)
+  }
+  defer tf.Close()
+
+  line := "The quick brown fox jumps over the lazy dog, ça va très bien ✓\n"
+  data := strings.Repeat(line, size/len(line))
+
+  if _, err := tf.WriteString(data); err != nil {
+    b.Fatalf("Error writing temp file: %s", err)
+  }
+
+  return tf.Name()
+}
+
+// benchmarkCountFile counts a 64MB file using jobs goroutines
+func benchmarkCountFile(b *testing.B, jobs int) {
+  fname := createBenchFile(b, 64*1024*1024)
+  defer os.Remove(fname)
+
+  b.ResetTimer()
+
+  for i := 0; i < b.N; i++ {
+    if fr := countFile(fname, config{jobs: jobs}); fr.err != nil {
+      b.Fatal(fr.err)
+    }
+  }
+}
+
+func BenchmarkCountSequential(b *testing.B) {
+  benchmarkCountFile(b, 1)
+}
+
+func BenchmarkCountParallel(b *testing.B) {
+  benchmarkCountFile(b, runtime.NumCPU())
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "path/filepath"
+  "strconv"
+  "strings"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// columns selects which counters are printed. They are always printed in
+// the same order as GNU wc: lines, words, runes, bytes, max line length,
+// followed by the invalid UTF-8 bytes GNU wc doesn't report
+type columns struct {
+  lines   bool
+  words   bool
+  runes   bool
+  bytes   bool
+  maxLine bool
+  invalid bool
+}
+
+// any reports whether at least one column is selected
+func (c columns) any() bool {
+  return c.lines || c.words || c.runes || c.bytes || c.maxLine || c.invalid
+}
+
+// values returns the selected counters of res in printing order
+func (c columns) values(res wc.Result) []int {
+  values := []int{}
+
+  if c.lines {
+    values = append(values, res.Lines)
+  }
+  if c.words {
+    values = append(values, res.Words)
+  }
+  if c.runes {
+    values = append(values, res.Runes)
+  }
+  if c.bytes {
+    values = append(values, res.Bytes)
+  }
+  if c.maxLine {
+    values = append(values, res.MaxLine)
+  }
+  if c.invalid {
+    values = append(values, res.Invalid)
+  }
+
+  return values
+}
+
+// width returns the number of digits of the widest selected counter
+func (c columns) width(res wc.Result) int {
+  w := 0
+
+  for _, v := range c.values(res) {
+    if l := len(strconv.Itoa(v)); l > w {
+      w = l
+    }
+  }
+
+  return w
+}
+
+// format returns the selected counters of res separated by spaces and
+// right aligned to width
+func (c columns) format(res wc.Result, width int) string {
+  fields := []string{}
+
+  for _, v := range c.values(res) {
+    fields = append(fields, fmt.Sprintf("%*d", width, v))
+  }
+
+  return strings.Join(fields, " ")
+}
+
+// names returns the names of the selected counters in printing order,
+// used as headers in machine readable output
+func (c columns) names() []string {
+  names := []string{}
+
+  if c.lines {
+    names = append(names, "lines")
+  }
+  if c.words {
+    names = append(names, "words")
+  }
+  if c.runes {
+    names = append(names, "chars")
+  }
+  if c.bytes {
+    names = append(names, "bytes")
+  }
+  if c.maxLine {
+    names = append(names, "max_line_length")
+  }
+  if c.invalid {
+    names = append(names, "invalid")
+  }
+
+  return names
+}
+
+// stdinName is the name of the Standard Input in machine readable output
+const stdinName = "-"
+
+// report holds the counters of every input that was counted successfully
+type report struct {
+  rows  []fileResult
+  total wc.Result
+  // print the total of all rows
+  showTotal bool
+}
+
+// jsonCounts holds the counters of a single row in JSON output. Counters
+// that were not selected are left out
+type jsonCounts struct {
+  File    string `json:"file,omitempty"`
+  Lines   *int   `json:"lines,omitempty"`
+  Words   *int   `json:"words,omitempty"`
+  Chars   *int   `json:"chars,omitempty"`
+  Bytes   *int   `json:"bytes,omitempty"`
+  MaxLine *int   `json:"max_line_length,omitempty"`
+  Invalid *int   `json:"invalid,omitempty"`
+}
+
+// jsonReport is the JSON output. It always includes the total so scripts
+// can rely on it
+type jsonReport struct {
+  Files []jsonCounts `json:"files"`
+  Dirs  []jsonCounts `json:"directories,omitempty"`
+  Total jsonCounts   `json:"total"`
+}
+
+// print writes the report to out in the given format
+func (r report) print(out io.Writer, cols columns, format string) error {
+  switch format {
+  case "json":
+    return r.printJSON(out, cols)
+  case "csv":
+    return r.printCSV(out, cols)
+  }
+
+  return r.printText(out, cols)
+}
+
+// printText prints one row per file with the counters aligned in columns,
+// followed by the total. The Standard Input is printed without a name
+func (r report) printText(out io.Writer, cols columns) error {
+  if len(r.rows) == 1 && r.rows[0].name == "" {
+    _, err := fmt.Fprintln(out, cols.format(r.rows[0].res, 0))
+    return err
+  }
+
+  // The total is the widest value so it defines the columns width
+  width := cols.width(r.total)
+
+  for _, fr := range r.rows {
+    if _, err := fmt.Fprintf(out, "%s %s\n",
+      cols.format(fr.res, width), rowName(fr)); err != nil {
+      return err
+    }
+  }
+
+  if r.showTotal {
+    if _, err := fmt.Fprintf(out, "%s total\n",
+      cols.format(r.total, width)); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// printJSON prints the report as a single JSON object
+func (r report) printJSON(out io.Writer, cols columns) error {
+  jr := jsonReport{
+    Files: []jsonCounts{},
+    Total: cols.jsonCounts("", r.total),
+  }
+
+  for _, fr := range r.rows {
+    if fr.subtotal {
+      jr.Dirs = append(jr.Dirs, cols.jsonCounts(rowName(fr), fr.res))
+      continue
+    }
+    jr.Files = append(jr.Files, cols.jsonCounts(rowName(fr), fr.res))
+  }
+
+  enc := json.NewEncoder(out)
+  enc.SetIndent("", "  ")
+
+  return enc.Encode(jr)
+}
+
+// printCSV prints a header followed by one record per file and the total,
+// like the text output
+func (r report) printCSV(out io.Writer, cols columns) error {
+  w := csv.NewWriter(out)
+
+  if err := w.Write(append([]string{"file"}, cols.names()...)); err != nil {
+    return err
+  }
+
+  for _, fr := range r.rows {
+    if err := w.Write(cols.record(rowName(fr), fr.res)); err != nil {
+      return err
+    }
+  }
+
+  if r.showTotal {
+    if err := w.Write(cols.record("total", r.total)); err != nil {
+      return err
+    }
+  }
+
+  w.Flush()
+
+  return w.Error()
+}
+
+// rowName returns the name printed for the row. Subtotals of directories
+// end with a path separator
+func rowName(fr fileResult) string {
+  if fr.name == "" {
+    return stdinName
+  }
+
+  if fr.subtotal {
+    return strings.TrimSuffix(fr.name, string(filepath.Separator)) +
+      string(filepath.Separator)
+  }
+
+  return fr.name
+}
+
+// record returns the name followed by the selected counters of res
+func (c columns) record(name string, res wc.Result) []string {
+  rec := []string{name}
+
+  for _, v := range c.values(res) {
+    rec = append(rec, strconv.Itoa(v))
+  }
+
+  return rec
+}
+
+// jsonCounts returns the selected counters of res ready to be encoded
+func (c columns) jsonCounts(name string, res wc.Result) jsonCounts {
+  jc := jsonCounts{File: name}
+
+  if c.lines {
+    jc.Lines = &res.Lines
+  }
+  if c.words {
+    jc.Words = &res.Words
+  }
+  if c.runes {
+    jc.Chars = &res.Runes
+  }
+  if c.bytes {
+    jc.Bytes = &res.Bytes
+  }
+  if c.maxLine {
+    jc.MaxLine = &res.MaxLine
+  }
+  if c.invalid {
+    jc.Invalid = &res.Invalid
+  }
+
+  return jc
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+  "testing"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// TestFormat tests the columns selected for printing
+func TestFormat(t *testing.T) {
+  res := wc.Result{Lines: 3, Words: 6, Bytes: 37, Runes: 33, MaxLine: 16}
+
+  testCases := []struct {
+    name string
+    cols columns
+    exp  string
+  }{
+    {name: "Words", cols: columns{words: true}, exp: "6"},
+    {name: "LinesBytes", cols: columns{lines: true, bytes: true},
+      exp: "3 37"},
+    {name: "All", cols: columns{lines: true, words: true, runes: true,
+      bytes: true, maxLine: true}, exp: "3 6 33 37 16"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      out := tc.cols.format(res, 0)
+
+      if out != tc.exp {
+        t.Errorf("Expected %q, got %q instead.\n", tc.exp, out)
+      }
+    })
+  }
+}
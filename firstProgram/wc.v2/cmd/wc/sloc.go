@@ -0,0 +1,381 @@
+package main
+
+import (
+  "bufio"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// lineKind is the classification of a single line of source code
+type lineKind int
+
+const (
+  blankLine lineKind = iota
+  commentLine
+  codeLine
+)
+
+// delimiters of a block comment
+type block struct {
+  start string
+  end   string
+}
+
+// language defines the comment syntax used to classify lines
+type language struct {
+  name   string
+  line   []string
+  blocks []block
+}
+
+var (
+  cBlock     = []block{{"/*", "*/"}}
+  otherLang  = language{name: "Other"}
+  goLang     = language{name: "Go", line: []string{"//"}, blocks: cBlock}
+  shellLang  = language{name: "Shell", line: []string{"#"}}
+  pythonLang = language{name: "Python", line: []string{"#"},
+    blocks: []block{{`"""`, `"""`}, {"'''", "'''"}}}
+  markdownLang = language{name: "Markdown",
+    blocks: []block{{"<!--", "-->"}}}
+)
+
+// cFamily returns a language with the C comment syntax
+func cFamily(name string) language {
+  return language{name: name, line: []string{"//"}, blocks: cBlock}
+}
+
+// languages maps file extensions to their language
+var languages = map[string]language{
+  ".go":       goLang,
+  ".sh":       shellLang,
+  ".bash":     shellLang,
+  ".zsh":      shellLang,
+  ".py":       pythonLang,
+  ".md":       markdownLang,
+  ".markdown": markdownLang,
+  ".c":        cFamily("C"),
+  ".h":        cFamily("C"),
+  ".cc":       cFamily("C++"),
+  ".cpp":      cFamily("C++"),
+  ".cxx":      cFamily("C++"),
+  ".hpp":      cFamily("C++"),
+  ".cs":       cFamily("C#"),
+  ".java":     cFamily("Java"),
+  ".js":       cFamily("JavaScript"),
+  ".ts":       cFamily("TypeScript"),
+  ".rs":       cFamily("Rust"),
+  ".swift":    cFamily("Swift"),
+  ".kt":       cFamily("Kotlin"),
+}
+
+// slocResult holds the number of lines of each kind
+type slocResult struct {
+  Blank   int `json:"blank"`
+  Comment int `json:"comment"`
+  Code    int `json:"code"`
+}
+
+// languageFor detects the language of a file by its extension, ignoring
+// the extension of compressed files
+func languageFor(fname string) language {
+  ext := strings.ToLower(filepath.Ext(fname))
+
+  switch ext {
+  case ".gz", ".bz2", ".zz":
+    ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(fname,
+      filepath.Ext(fname))))
+  }
+
+  if l, ok := languages[ext]; ok {
+    return l
+  }
+
+  return otherLang
+}
+
+// countSloc classifies every line read from r as code, comment or blank.
+// Lines with code and comments count as code. Comment markers inside
+// strings are not detected
+func countSloc(r io.Reader, lang language) (slocResult, error) {
+  br := bufio.NewReader(r)
+
+  res := slocResult{}
+  // end delimiter of the open block comment, if any
+  end := ""
+
+  for {
+    line, err := br.ReadString('\n')
+    if err != nil && err != io.EOF {
+      return res, err
+    }
+
+    if len(line) > 0 {
+      var kind lineKind
+      kind, end = lang.classify(line, end)
+
+      switch kind {
+      case blankLine:
+        res.Blank++
+      case commentLine:
+        res.Comment++
+      case codeLine:
+        res.Code++
+      }
+    }
+
+    if err == io.EOF {
+      return res, nil
+    }
+  }
+}
+
+// classify returns the kind of line and the end delimiter of a block
+// comment still open after it. end is the delimiter of the block comment
+// open before the line
+func (l language) classify(line, end string) (lineKind, string) {
+  line = strings.TrimSpace(line)
+
+  if end != "" {
+    i := strings.Index(line, end)
+    if i < 0 {
+      return commentLine, end
+    }
+
+    rest := strings.TrimSpace(line[i+len(end):])
+    if rest == "" {
+      return commentLine, ""
+    }
+
+    // Something follows the end of the comment
+    return l.classify(rest, "")
+  }
+
+  if line == "" {
+    return blankLine, ""
+  }
+
+  for _, p := range l.line {
+    if strings.HasPrefix(line, p) {
+      return commentLine, ""
+    }
+  }
+
+  for _, b := range l.blocks {
+    if strings.HasPrefix(line, b.start) {
+      kind, end := l.classify(line[len(b.start):], b.end)
+      if kind == blankLine {
+        kind = commentLine
+      }
+      return kind, end
+    }
+  }
+
+  // A block comment may start after the code
+  return codeLine, l.openBlock(line)
+}
+
+// openBlock returns the end delimiter of a block comment left open at the
+// end of a line of code, or "" when all of them are closed
+func (l language) openBlock(line string) string {
+  for {
+    // Find the first comment marker
+    first, isLine := -1, false
+    open := block{}
+
+    for _, p := range l.line {
+      if i := strings.Index(line, p); i >= 0 && (first < 0 || i < first) {
+        first, isLine = i, true
+      }
+    }
+
+    for _, b := range l.blocks {
+      if i := strings.Index(line, b.start); i >= 0 &&
+        (first < 0 || i < first) {
+        first, isLine, open = i, false, b
+      }
+    }
+
+    // Block markers after a line comment are part of the comment
+    if first < 0 || isLine {
+      return ""
+    }
+
+    rest := line[first+len(open.start):]
+    i := strings.Index(rest, open.end)
+    if i < 0 {
+      return open.end
+    }
+    line = rest[i+len(open.end):]
+  }
+}
+
+// add accumulates the counters of o into s
+func (s *slocResult) add(o slocResult) {
+  s.Blank += o.Blank
+  s.Comment += o.Comment
+  s.Code += o.Code
+}
+
+// langTotal holds the totals of all files of a language
+type langTotal struct {
+  Language string `json:"language,omitempty"`
+  Files    int    `json:"files"`
+  slocResult
+}
+
+// slocRow is a file in JSON output
+type slocRow struct {
+  File     string `json:"file"`
+  Language string `json:"language"`
+  slocResult
+}
+
+// slocReport is the JSON output of the sloc mode
+type slocReport struct {
+  Files     []slocRow   `json:"files"`
+  Languages []langTotal `json:"languages,omitempty"`
+  Total     langTotal   `json:"total"`
+}
+
+// newSlocReport groups the rows by language. Languages are sorted by name
+// so the output is stable
+func newSlocReport(rows []fileResult) slocReport {
+  sr := slocReport{Files: []slocRow{}}
+  byLang := map[string]*langTotal{}
+
+  for _, fr := range rows {
+    sr.Files = append(sr.Files, slocRow{File: rowName(fr),
+      Language: fr.lang, slocResult: fr.sloc})
+
+    lt, ok := byLang[fr.lang]
+    if !ok {
+      lt = &langTotal{Language: fr.lang}
+      byLang[fr.lang] = lt
+    }
+    lt.Files++
+    lt.add(fr.sloc)
+
+    sr.Total.Files++
+    sr.Total.add(fr.sloc)
+  }
+
+  for _, lt := range byLang {
+    sr.Languages = append(sr.Languages, *lt)
+  }
+
+  sort.Slice(sr.Languages, func(i, j int) bool {
+    return sr.Languages[i].Language < sr.Languages[j].Language
+  })
+
+  return sr
+}
+
+// printSloc prints the lines of every file. The totals by language are
+// printed only when byLang is set
+func printSloc(out io.Writer, rows []fileResult, byLang bool,
+  format string) error {
+
+  sr := newSlocReport(rows)
+  if !byLang {
+    sr.Languages = nil
+  }
+
+  switch format {
+  case "json":
+    enc := json.NewEncoder(out)
+    enc.SetIndent("", "  ")
+    return enc.Encode(sr)
+  case "csv":
+    return sr.printCSV(out)
+  }
+
+  return sr.printText(out)
+}
+
+// printCSV prints one record per file followed by the totals by language
+// and the total of all files, named "total"
+func (sr slocReport) printCSV(out io.Writer) error {
+  w := csv.NewWriter(out)
+  w.Write([]string{"file", "language", "blank", "comment", "code"})
+
+  record := func(file, lang string, s slocResult) []string {
+    return []string{file, lang, strconv.Itoa(s.Blank),
+      strconv.Itoa(s.Comment), strconv.Itoa(s.Code)}
+  }
+
+  for _, r := range sr.Files {
+    w.Write(record(r.File, r.Language, r.slocResult))
+  }
+
+  if sr.Languages != nil {
+    for _, lt := range sr.Languages {
+      w.Write(record("total", lt.Language, lt.slocResult))
+    }
+    w.Write(record("total", "", sr.Total.slocResult))
+  }
+
+  w.Flush()
+
+  return w.Error()
+}
+
+// printText prints a table of files followed by a table of languages
+func (sr slocReport) printText(out io.Writer) error {
+  // Columns are as wide as their header or the total
+  wb := width("Blank", sr.Total.Blank)
+  wc := width("Comment", sr.Total.Comment)
+  wl := width("Code", sr.Total.Code)
+
+  wlang := len("Language")
+  for _, r := range sr.Files {
+    if len(r.Language) > wlang {
+      wlang = len(r.Language)
+    }
+  }
+
+  fmt.Fprintf(out, "%*s %*s %*s %-*s %s\n", wb, "Blank", wc, "Comment",
+    wl, "Code", wlang, "Language", "File")
+  for _, r := range sr.Files {
+    fmt.Fprintf(out, "%*d %*d %*d %-*s %s\n", wb, r.Blank, wc, r.Comment,
+      wl, r.Code, wlang, r.Language, r.File)
+  }
+
+  if sr.Languages == nil {
+    return nil
+  }
+
+  wf := width("Files", sr.Total.Files)
+
+  fmt.Fprintf(out, "\n%*s %*s %*s %*s %s\n", wb, "Blank", wc, "Comment",
+    wl, "Code", wf, "Files", "Language")
+
+  for _, lt := range append(sr.Languages, sr.Total) {
+    name := lt.Language
+    if name == "" {
+      name = "Total"
+    }
+
+    if _, err := fmt.Fprintf(out, "%*d %*d %*d %*d %s\n", wb, lt.Blank,
+      wc, lt.Comment, wl, lt.Code, wf, lt.Files, name); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// width returns the width of a column with the given header and maximum
+// value
+func width(header string, max int) int {
+  if l := len(strconv.Itoa(max)); l > len(header) {
+    return l
+  }
+
+  return len(header)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+// TestCountSloc tests the classification of lines for each language
+func TestCountSloc(t *testing.T) {
+  testCases := []struct {
+    file string
+    lang string
+    exp  slocResult
+  }{
+    {file: "testdata/sloc/main.go", lang: "Go",
+      exp: slocResult{Blank: 4, Comment: 5, Code: 5}},
+    {file: "testdata/sloc/script.sh", lang: "Shell",
+      exp: slocResult{Blank: 1, Comment: 2, Code: 1}},
+    {file: "testdata/sloc/tool.py", lang: "Python",
+      exp: slocResult{Blank: 1, Comment: 5, Code: 2}},
+    {file: "testdata/sloc/README.md", lang: "Markdown",
+      exp: slocResult{Blank: 1, Comment: 2, Code: 2}},
+    {file: "testdata/one.txt", lang: "Other",
+      exp: slocResult{Code: 3}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.lang, func(t *testing.T) {
+      fr := countFile(tc.file, config{sloc: true})
+      if fr.err != nil {
+        t.Fatal(fr.err)
+      }
+
+      if fr.lang != tc.lang {
+        t.Errorf("Expected language %q, got %q instead", tc.lang, fr.lang)
+      }
+
+      if fr.sloc != tc.exp {
+        t.Errorf("Expected %+v, got %+v instead", tc.exp, fr.sloc)
+      }
+    })
+  }
+}
+
+// TestClassify tests block comments closing in the middle of a line
+func TestClassify(t *testing.T) {
+  testCases := []struct {
+    name  string
+    lang  language
+    input string
+    exp   slocResult
+  }{
+    {name: "CodeAfterComment", lang: goLang,
+      input: "/* a\nb */ x := 1\n/* c */ /* d\n*/\n\n",
+      exp:   slocResult{Blank: 1, Comment: 3, Code: 1}},
+    {name: "CommentAfterCode", lang: goLang,
+      input: "var x = 1 /* start\ncomment\nmore\n*/\nvar y = 2\n",
+      exp:   slocResult{Comment: 3, Code: 2}},
+    {name: "ClosedAfterCode", lang: goLang,
+      input: "x := 1 /* a */ /* b */ + 2\ny := 2\n",
+      exp:   slocResult{Code: 2}},
+    {name: "BlockInLineComment", lang: goLang,
+      input: "x := 1 // not a /* block\ny := 2\n",
+      exp:   slocResult{Code: 2}},
+    {name: "Docstring", lang: pythonLang,
+      input: "x = 1 \"\"\"start\ntext\n\"\"\"\n",
+      exp:   slocResult{Comment: 2, Code: 1}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      res, err := countSloc(strings.NewReader(tc.input), tc.lang)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if res != tc.exp {
+        t.Errorf("Expected %+v, got %+v instead", tc.exp, res)
+      }
+    })
+  }
+}
+
+// TestRunSloc tests the report with totals by language
+func TestRunSloc(t *testing.T) {
+  testCases := []struct {
+    name   string
+    format string
+    files  []string
+    exp    string
+  }{
+    {name: "Recursive", format: "text", files: []string{"testdata/sloc"},
+      exp: `Blank Comment Code Language File
+    1       2    2 Markdown testdata/sloc/README.md
+    4       5    5 Go       testdata/sloc/main.go
+    1       2    1 Shell    testdata/sloc/script.sh
+    1       5    2 Python   testdata/sloc/tool.py
+
+Blank Comment Code Files Language
+    4       5    5     1 Go
+    1       2    2     1 Markdown
+    1       5    2     1 Python
+    1       2    1     1 Shell
+    7      14   10     4 Total
+`},
+    {name: "SingleFileCSV", format: "csv",
+      files: []string{"testdata/sloc/main.go"},
+      exp: "file,language,blank,comment,code\n" +
+        "testdata/sloc/main.go,Go,4,5,5\n" +
+        "total,Go,4,5,5\ntotal,,4,5,5\n"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out bytes.Buffer
+
+      c := config{sloc: true, recursive: true, format: tc.format,
+        wErr: &out}
+
+      if err := run(tc.files, nil, &out, c); err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
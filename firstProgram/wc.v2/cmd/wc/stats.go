@@ -0,0 +1,296 @@
+package main
+
+import (
+  "bufio"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// maxBuckets is the largest number of buckets in the histogram and
+// barWidth the length of the longest bar
+const (
+  maxBuckets = 10
+  barWidth   = 40
+)
+
+// lineLengths counts the lines of every length, measured in runes like
+// the longest line. Keeping one counter per length instead of every line
+// uses little memory on large inputs
+type lineLengths map[int]int
+
+// countLengths measures every line of r. The last line counts even if
+// it's not terminated by a new line
+func countLengths(r io.Reader) (lineLengths, error) {
+  ll := lineLengths{}
+  br := bufio.NewReader(r)
+
+  // Runes are read one at a time so lines of any length fit in memory
+  n, inLine := 0, false
+  for {
+    ru, _, err := br.ReadRune()
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      return ll, err
+    }
+
+    if ru == '\n' {
+      ll[n]++
+      n, inLine = 0, false
+      continue
+    }
+
+    n++
+    inLine = true
+  }
+
+  if inLine {
+    ll[n]++
+  }
+
+  return ll, nil
+}
+
+// add accumulates the lines of o into ll
+func (ll lineLengths) add(o lineLengths) {
+  for n, lines := range o {
+    ll[n] += lines
+  }
+}
+
+// lineStats summarizes the lengths of the lines of an input
+type lineStats struct {
+  Lines  int     `json:"lines"`
+  Min    int     `json:"min"`
+  Max    int     `json:"max"`
+  Mean   float64 `json:"mean"`
+  Median int     `json:"median"`
+  P95    int     `json:"p95"`
+}
+
+// sorted returns the lengths found in ascending order
+func (ll lineLengths) sorted() []int {
+  lengths := make([]int, 0, len(ll))
+  for n := range ll {
+    lengths = append(lengths, n)
+  }
+  sort.Ints(lengths)
+
+  return lengths
+}
+
+// stats calculates the statistics of ll. Percentiles use the nearest rank
+// so they are always the length of an actual line
+func (ll lineLengths) stats() lineStats {
+  lengths := ll.sorted()
+  if len(lengths) == 0 {
+    return lineStats{}
+  }
+
+  s := lineStats{Min: lengths[0], Max: lengths[len(lengths)-1]}
+  sum := 0
+  for _, n := range lengths {
+    s.Lines += ll[n]
+    sum += n * ll[n]
+  }
+  s.Mean = float64(sum) / float64(s.Lines)
+
+  // rank returns the length of the line at the p percentile
+  rank := func(p int) int {
+    r := (p*s.Lines + 99) / 100
+    seen := 0
+    for _, n := range lengths {
+      seen += ll[n]
+      if seen >= r {
+        return n
+      }
+    }
+    return s.Max
+  }
+
+  s.Median = rank(50)
+  s.P95 = rank(95)
+
+  return s
+}
+
+// bucket is a range of line lengths in the histogram
+type bucket struct {
+  Min   int `json:"min"`
+  Max   int `json:"max"`
+  Lines int `json:"lines"`
+}
+
+// histogram groups the lines of ll in up to maxBuckets buckets of the
+// same size, picked from 1, 2, 5, 10, 20, 50... so ranges are easy to read
+func (ll lineLengths) histogram() []bucket {
+  if len(ll) == 0 {
+    return []bucket{}
+  }
+
+  max := ll.sorted()[len(ll)-1]
+
+  size := 1
+  for i := 0; max/size >= maxBuckets; i++ {
+    size = []int{2, 5, 10}[i%3] * pow10(i/3)
+  }
+
+  buckets := make([]bucket, max/size+1)
+  for i := range buckets {
+    buckets[i] = bucket{Min: i * size, Max: (i+1)*size - 1}
+  }
+
+  for n, lines := range ll {
+    buckets[n/size].Lines += lines
+  }
+
+  return buckets
+}
+
+// pow10 returns 10 to the power of e
+func pow10(e int) int {
+  p := 1
+  for i := 0; i < e; i++ {
+    p *= 10
+  }
+
+  return p
+}
+
+// statsRow is a file in JSON output
+type statsRow struct {
+  File string `json:"file"`
+  lineStats
+}
+
+// statsReport is the JSON output of the stats mode. The histogram is of
+// the lines of all files
+type statsReport struct {
+  Files     []statsRow `json:"files"`
+  Total     lineStats  `json:"total"`
+  Histogram []bucket   `json:"histogram"`
+}
+
+// printStats prints the line length statistics of every file followed by
+// the total, when showTotal is set, and the histogram of all lines
+func printStats(out io.Writer, rows []fileResult, showTotal bool,
+  format string) error {
+
+  total := lineLengths{}
+  sr := statsReport{Files: []statsRow{}}
+
+  for _, fr := range rows {
+    name := rowName(fr)
+    if name == "" {
+      name = stdinName
+    }
+
+    sr.Files = append(sr.Files, statsRow{File: name,
+      lineStats: fr.lengths.stats()})
+    total.add(fr.lengths)
+  }
+
+  sr.Total = total.stats()
+  sr.Histogram = total.histogram()
+
+  switch format {
+  case "json":
+    enc := json.NewEncoder(out)
+    enc.SetIndent("", "  ")
+    return enc.Encode(sr)
+  case "csv":
+    return sr.printCSV(out, showTotal)
+  }
+
+  return sr.printText(out, showTotal)
+}
+
+// printCSV prints one record per file followed by the total, named
+// "total". The histogram isn't part of the CSV output
+func (sr statsReport) printCSV(out io.Writer, showTotal bool) error {
+  w := csv.NewWriter(out)
+  w.Write([]string{"file", "lines", "min", "max", "mean", "median", "p95"})
+
+  rows := sr.Files
+  if showTotal {
+    rows = append(rows, statsRow{File: "total", lineStats: sr.Total})
+  }
+
+  for _, r := range rows {
+    w.Write([]string{r.File, strconv.Itoa(r.Lines), strconv.Itoa(r.Min),
+      strconv.Itoa(r.Max), strconv.FormatFloat(r.Mean, 'f', 1, 64),
+      strconv.Itoa(r.Median), strconv.Itoa(r.P95)})
+  }
+
+  w.Flush()
+
+  return w.Error()
+}
+
+// printText prints a table of files followed by the histogram with bars
+// scaled to the bucket with the most lines
+func (sr statsReport) printText(out io.Writer, showTotal bool) error {
+  rows := sr.Files
+  if showTotal {
+    rows = append(rows, statsRow{File: "total", lineStats: sr.Total})
+  }
+
+  // Columns are as wide as their header or the total
+  wl := width("Lines", sr.Total.Lines)
+  wn := width("Min", sr.Total.Min)
+  wx := width("Max", sr.Total.Max)
+  wm := len(strconv.FormatFloat(float64(sr.Total.Max), 'f', 1, 64))
+  if wm < len("Mean") {
+    wm = len("Mean")
+  }
+  wd := width("Median", sr.Total.Max)
+  wp := width("P95", sr.Total.Max)
+
+  fmt.Fprintf(out, "%*s %*s %*s %*s %*s %*s %s\n", wl, "Lines", wn, "Min",
+    wx, "Max", wm, "Mean", wd, "Median", wp, "P95", "File")
+  for _, r := range rows {
+    fmt.Fprintf(out, "%*d %*d %*d %*.1f %*d %*d %s\n", wl, r.Lines, wn,
+      r.Min, wx, r.Max, wm, r.Mean, wd, r.Median, wp, r.P95, r.File)
+  }
+
+  if len(sr.Histogram) == 0 {
+    return nil
+  }
+
+  most := 0
+  for _, b := range sr.Histogram {
+    if b.Lines > most {
+      most = b.Lines
+    }
+  }
+
+  last := sr.Histogram[len(sr.Histogram)-1]
+  wr := len(fmt.Sprintf("%d-%d", last.Min, last.Max))
+  if wr < len("Length") {
+    wr = len("Length")
+  }
+
+  fmt.Fprintf(out, "\n%-*s Lines\n", wr, "Length")
+  for _, b := range sr.Histogram {
+    // Any line at all shows at least one mark
+    bar := (b.Lines*barWidth + most - 1) / most
+
+    r := strconv.Itoa(b.Min)
+    if b.Max > b.Min {
+      r = fmt.Sprintf("%d-%d", b.Min, b.Max)
+    }
+
+    if _, err := fmt.Fprintf(out, "%-*s |%-*s %d\n", wr, r, barWidth,
+      strings.Repeat("#", bar), b.Lines); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+  "bytes"
+  "reflect"
+  "strings"
+  "testing"
+)
+
+// TestLineStats tests the statistics of the line lengths of an input
+func TestLineStats(t *testing.T) {
+  testCases := []struct {
+    name  string
+    input string
+    exp   lineStats
+  }{
+    {name: "Empty", input: "", exp: lineStats{}},
+    {name: "Unterminated", input: "abc\nde",
+      exp: lineStats{Lines: 2, Min: 2, Max: 3, Mean: 2.5, Median: 2,
+        P95: 3}},
+    {name: "MultiByte", input: "héllo\nwörld!\n\n",
+      exp: lineStats{Lines: 3, Min: 0, Max: 6, Mean: 11.0 / 3, Median: 5,
+        P95: 6}},
+    {name: "Outlier", input: strings.Repeat("0123456789\n", 99) +
+      strings.Repeat("x", 500) + "\n",
+      exp: lineStats{Lines: 100, Min: 10, Max: 500, Mean: 14.9,
+        Median: 10, P95: 10}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      ll, err := countLengths(strings.NewReader(tc.input))
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if res := ll.stats(); res != tc.exp {
+        t.Errorf("Expected %+v, got %+v instead", tc.exp, res)
+      }
+    })
+  }
+}
+
+// TestHistogram tests the size of the buckets for different lengths
+func TestHistogram(t *testing.T) {
+  ll := lineLengths{0: 2, 37: 1, 152: 4}
+
+  exp := []bucket{{0, 19, 2}, {20, 39, 1}, {40, 59, 0}, {60, 79, 0},
+    {80, 99, 0}, {100, 119, 0}, {120, 139, 0}, {140, 159, 4}}
+
+  if res := ll.histogram(); !reflect.DeepEqual(res, exp) {
+    t.Errorf("Expected %+v, got %+v instead", exp, res)
+  }
+}
+
+// TestRunStats tests the statistics of the Standard Input and files
+func TestRunStats(t *testing.T) {
+  testCases := []struct {
+    name   string
+    files  []string
+    format string
+    exp    string
+  }{
+    {name: "Stdin", format: "text",
+      exp: "Lines Min Max Mean Median P95 File\n" +
+        "    3   1   8  3.7      2   8 -\n" +
+        "\nLength Lines\n" +
+        "0      |                                         0\n" +
+        "1      |########################################" +
+        " 1\n" +
+        "2      |########################################" +
+        " 1\n" +
+        "3      |                                         0\n" +
+        "4      |                                         0\n" +
+        "5      |                                         0\n" +
+        "6      |                                         0\n" +
+        "7      |                                         0\n" +
+        "8      |########################################" +
+        " 1\n"},
+    {name: "MultiFilesCSV", format: "csv",
+      files: []string{"testdata/one.txt", "testdata/two.txt"},
+      exp: "file,lines,min,max,mean,median,p95\n" +
+        "testdata/one.txt,3,5,17,11.0,11,17\n" +
+        "testdata/two.txt,30,43,43,43.0,43,43\n" +
+        "total,33,5,43,40.1,43,43\n"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+      in := strings.NewReader("a\nbb\nlast one")
+
+      c := config{stats: true, workers: 2, wErr: &errOut,
+        format: tc.format}
+
+      if err := run(tc.files, in, &out, c); err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
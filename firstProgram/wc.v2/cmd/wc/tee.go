@@ -0,0 +1,110 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "sync"
+  "time"
+
+  "pragprog.com/rggo/firstProgram/wc"
+)
+
+// syncCounter is a wc.Counter safe to write to while another goroutine
+// reads its counters. Bytes are counted in raw, before decoding them
+type syncCounter struct {
+  mu  sync.Mutex
+  c   *wc.Counter
+  raw int
+}
+
+func (s *syncCounter) Write(p []byte) (int, error) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.c.Write(p)
+}
+
+func (s *syncCounter) result() wc.Result {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  res := s.c.Result()
+  res.Bytes = s.raw
+  return res
+}
+
+// rawCounter counts the bytes written to it in the raw bytes of s
+type rawCounter struct {
+  s *syncCounter
+}
+
+func (r rawCounter) Write(p []byte) (int, error) {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  r.s.raw += len(p)
+  return len(p), nil
+}
+
+// tee copies in to out unchanged while counting it, so it can be placed
+// between two stages of a pipeline. The input is counted as it passes
+// through, without buffering it. The counters are printed to cfg.wErr
+// when in ends, and with cfg.progress the bytes and lines per second are
+// printed there every cfg.interval too
+func tee(in io.Reader, out io.Writer, cfg config) error {
+  c := &syncCounter{c: wc.NewCounter(wc.Options{Tokenizer: cfg.tokenizer})}
+
+  done := make(chan struct{})
+  wg := sync.WaitGroup{}
+
+  if cfg.progress {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      printProgress(cfg.wErr, c, cfg.interval, done)
+    }()
+  }
+
+  // The input is copied and its bytes counted as it's read, before
+  // converting it to UTF-8 to count the rest
+  r, _, err := wc.Decode(io.TeeReader(in, io.MultiWriter(out, rawCounter{c})),
+    cfg.encoding)
+  if err == nil {
+    _, err = io.Copy(c, r)
+  }
+
+  close(done)
+  wg.Wait()
+
+  if err != nil {
+    return fmt.Errorf("Cannot copy input: %s", err)
+  }
+
+  res := c.result()
+  rep := report{rows: []fileResult{{res: res}}, total: res}
+  return rep.print(cfg.wErr, cfg.cols, cfg.format)
+}
+
+// printProgress prints the bytes and lines counted so far to w every
+// interval, with the rate since the previous report, until done is closed
+func printProgress(w io.Writer, c *syncCounter, interval time.Duration,
+  done <-chan struct{}) {
+
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+
+  last, lastTime := wc.Result{}, time.Now()
+
+  for {
+    select {
+    case <-done:
+      return
+    case now := <-ticker.C:
+      res := c.result()
+      secs := now.Sub(lastTime).Seconds()
+
+      fmt.Fprintf(w, "%d bytes, %d lines, %.0f bytes/sec, %.0f lines/sec\n",
+        res.Bytes, res.Lines, float64(res.Bytes-last.Bytes)/secs,
+        float64(res.Lines-last.Lines)/secs)
+
+      last, lastTime = res, now
+    }
+  }
+}
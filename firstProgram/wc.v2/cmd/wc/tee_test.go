@@ -0,0 +1,122 @@
+package main
+
+import (
+  "bytes"
+  "io"
+  "strings"
+  "testing"
+  "time"
+)
+
+// TestTee tests that the input is copied unchanged while the counters
+// are printed to the error output
+func TestTee(t *testing.T) {
+  input := "word1 word2\nline2 word3 word4\nlast"
+
+  testCases := []struct {
+    name      string
+    filenames []string
+    format    string
+    stats     bool
+    recursive bool
+    expCounts string
+    errMsg    string
+  }{
+    {name: "Text", format: "text", expCounts: "3 6 34\n"},
+    {name: "CSV", format: "csv",
+      expCounts: "file,lines,words,bytes\n-,3,6,34\n"},
+    {name: "FailFiles", format: "text", filenames: []string{"testdata/one.txt"},
+      errMsg: "Tee mode counts the Standard Input only"},
+    {name: "FailStats", format: "text", stats: true,
+      errMsg: "Tee mode counts the Standard Input only"},
+    {name: "FailRecursive", format: "text", recursive: true,
+      errMsg: "Tee mode counts the Standard Input only"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+
+      c := config{
+        cols:      columns{lines: true, words: true, bytes: true},
+        format:    tc.format,
+        tee:       true,
+        stats:     tc.stats,
+        recursive: tc.recursive,
+        wErr:      &errOut,
+      }
+
+      err := run(tc.filenames, strings.NewReader(input), &out, c)
+
+      if tc.errMsg != "" {
+        if err == nil {
+          t.Fatalf("Expected error. Got nil instead")
+        }
+
+        if !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Unexpected error message: %q", err)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != input {
+        t.Errorf("Expected output %q, got %q instead", input, out.String())
+      }
+
+      if errOut.String() != tc.expCounts {
+        t.Errorf("Expected counts %q, got %q instead", tc.expCounts,
+          errOut.String())
+      }
+    })
+  }
+}
+
+// TestTeeProgress tests the progress reports printed while the input is
+// still being received
+func TestTeeProgress(t *testing.T) {
+  pr, pw := io.Pipe()
+
+  var out, errOut syncBuffer
+  errCh := make(chan error)
+
+  c := config{
+    cols:     columns{lines: true},
+    format:   "text",
+    tee:      true,
+    progress: true,
+    interval: 10 * time.Millisecond,
+    wErr:     &errOut,
+  }
+
+  go func() {
+    errCh <- run(nil, pr, &out, c)
+  }()
+
+  if _, err := io.WriteString(pw, "one\ntwo\n"); err != nil {
+    t.Fatal(err)
+  }
+
+  // The input is counted as it arrives, not when it ends
+  waitFor(t, &errOut, "lines/sec\n")
+  if !strings.Contains(errOut.String(), "8 bytes, 2 lines, ") {
+    t.Errorf("Expected progress of 8 bytes, got %q instead", errOut.String())
+  }
+
+  pw.Close()
+
+  if err := <-errCh; err != nil {
+    t.Fatal(err)
+  }
+
+  waitFor(t, &errOut, "lines/sec\n2\n")
+
+  if out.String() != "one\ntwo\n" {
+    t.Errorf("Expected output %q, got %q instead", "one\ntwo\n",
+      out.String())
+  }
+}
@@ -0,0 +1,14 @@
+package main
+
+/*
+Block comment
+*/
+
+import "fmt" // trailing comment
+
+/* one line block */
+func main() {
+	// line comment
+
+	fmt.Println("hello") /* code first */
+}
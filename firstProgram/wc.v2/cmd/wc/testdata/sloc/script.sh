@@ -0,0 +1,4 @@
+#!/bin/bash
+# comment
+
+echo "hello" # trailing
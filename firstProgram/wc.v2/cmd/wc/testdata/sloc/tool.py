@@ -0,0 +1,8 @@
+"""Module docstring
+spanning lines
+"""
+
+# comment
+def main():
+    '''one line docstring'''
+    print("hello")
@@ -0,0 +1,159 @@
+package main
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+)
+
+// expandFiles replaces every directory in args by the regular files found
+// walking it recursively, filtered by the include and exclude patterns.
+// It returns the directory argument each file was found in, used to
+// calculate the subtotals, and the errors found while walking
+func expandFiles(args []string, cfg config) ([]string, map[string]string,
+  []error) {
+
+  files := []string{}
+  roots := map[string]string{}
+  errs := []error{}
+
+  for _, arg := range args {
+    info, err := os.Stat(arg)
+    if err != nil || !info.IsDir() {
+      // Anything else is counted as is. Errors are reported when
+      // the file is opened
+      files = append(files, arg)
+      continue
+    }
+
+    root := filepath.Clean(arg)
+
+    filepath.Walk(root,
+      func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+          errs = append(errs, fmt.Errorf("Cannot walk directory: %s", err))
+          return nil
+        }
+
+        if info.IsDir() {
+          if path != root && matchAny(cfg.exclude, info.Name()) {
+            return filepath.SkipDir
+          }
+          return nil
+        }
+
+        if !info.Mode().IsRegular() ||
+          filterOut(info.Name(), cfg.include, cfg.exclude) {
+          return nil
+        }
+
+        files = append(files, path)
+        roots[path] = root
+
+        return nil
+      })
+  }
+
+  return files, roots, errs
+}
+
+// filterOut reports whether the file name must be skipped because it
+// doesn't match any include pattern or matches an exclude pattern
+func filterOut(name string, include, exclude []string) bool {
+  if len(include) > 0 && !matchAny(include, name) {
+    return true
+  }
+
+  return matchAny(exclude, name)
+}
+
+// matchAny reports whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+  for _, p := range patterns {
+    if ok, _ := filepath.Match(p, name); ok {
+      return true
+    }
+  }
+
+  return false
+}
+
+// sniffBinary reports whether r looks like binary data because its first
+// block contains a NUL byte. It returns a reader with the contents of r
+// unchanged
+func sniffBinary(r io.Reader) (io.Reader, bool, error) {
+  br := bufio.NewReader(r)
+
+  block, err := br.Peek(sniffSize)
+  if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+    return nil, false, err
+  }
+
+  return br, bytes.IndexByte(block, 0) >= 0, nil
+}
+
+// addSubtotals returns rows with a subtotal row added after the files of
+// every directory walked, including its subdirectories. It relies on the
+// files of a directory being contiguous, as returned by filepath.Walk
+func addSubtotals(rows []fileResult, roots map[string]string) []fileResult {
+  out := []fileResult{}
+
+  // Directories containing the current file, from the root down
+  stack := []fileResult{}
+
+  pop := func(n int) {
+    for len(stack) > n {
+      top := stack[len(stack)-1]
+      stack = stack[:len(stack)-1]
+      out = append(out, top)
+    }
+  }
+
+  for _, fr := range rows {
+    chain := dirChain(fr.name, roots[fr.name])
+
+    // Close the directories that don't contain this file
+    common := 0
+    for common < len(stack) && common < len(chain) &&
+      stack[common].name == chain[common] {
+      common++
+    }
+    pop(common)
+
+    for _, d := range chain[common:] {
+      stack = append(stack, fileResult{name: d, subtotal: true})
+    }
+
+    for i := range stack {
+      stack[i].res.Add(fr.res)
+    }
+
+    out = append(out, fr)
+  }
+
+  pop(0)
+
+  return out
+}
+
+// dirChain returns the directories from root down to the one containing
+// name. It returns nil if name wasn't found walking a directory
+func dirChain(name, root string) []string {
+  if root == "" {
+    return nil
+  }
+
+  chain := []string{}
+  for d := filepath.Dir(name); ; d = filepath.Dir(d) {
+    chain = append([]string{d}, chain...)
+
+    if d == root || d == filepath.Dir(d) {
+      break
+    }
+  }
+
+  return chain
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// createTree creates a temporary directory with text and binary files
+func createTree(t *testing.T) string {
+  t.Helper()
+
+  tempDir, err := ioutil.TempDir("", "wctree")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  files := map[string]string{
+    "a.txt":          "one two\n",
+    "b.go":           "package main\n",
+    "bin.dat":        "\x00\x01abc",
+    "sub/c.txt":      "three four five\n",
+    "sub/deep/d.txt": "six\n",
+    "vendor/e.txt":   "skip me\n",
+    "z.txt":          "last\n",
+  }
+
+  for name, content := range files {
+    path := filepath.Join(tempDir, filepath.FromSlash(name))
+
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  return tempDir
+}
+
+// TestRunRecursive tests walking directories with filters and subtotals
+func TestRunRecursive(t *testing.T) {
+  tempDir := createTree(t)
+  defer os.RemoveAll(tempDir)
+
+  // p returns the path of a file in the tree
+  p := func(name string) string {
+    return filepath.Join(tempDir, filepath.FromSlash(name))
+  }
+  // d returns the name printed for a directory subtotal
+  d := func(name string) string {
+    return p(name) + string(filepath.Separator)
+  }
+
+  testCases := []struct {
+    name       string
+    include    []string
+    exclude    []string
+    skipBinary bool
+    exp        string
+  }{
+    {name: "Filtered", include: []string{"*.txt"},
+      exclude: []string{"vendor"}, skipBinary: true,
+      exp: fmt.Sprintf("2 %s\n3 %s\n1 %s\n1 %s\n4 %s\n1 %s\n7 %s\n7 total\n",
+        p("a.txt"), p("sub/c.txt"), p("sub/deep/d.txt"), d("sub/deep"),
+        d("sub"), p("z.txt"), d(""))},
+    {name: "SkipBinary", exclude: []string{"sub", "*.txt"},
+      skipBinary: true,
+      exp:        fmt.Sprintf("2 %s\n2 %s\n2 total\n", p("b.go"), d(""))},
+    {name: "WithBinary", exclude: []string{"sub", "*.txt"},
+      exp: fmt.Sprintf("2 %s\n1 %s\n3 %s\n3 total\n", p("b.go"),
+        p("bin.dat"), d(""))},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      var out, errOut bytes.Buffer
+
+      c := config{
+        cols:       columns{words: true},
+        format:     "text",
+        recursive:  true,
+        include:    tc.include,
+        exclude:    tc.exclude,
+        skipBinary: tc.skipBinary,
+        wErr:       &errOut,
+      }
+
+      if err := run([]string{tempDir}, nil, &out, c); err != nil {
+        t.Fatal(err)
+      }
+
+      if out.String() != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, out.String())
+      }
+    })
+  }
+}
+
+// TestRunRecursiveBadPattern tests that patterns are validated
+func TestRunRecursiveBadPattern(t *testing.T) {
+  var out bytes.Buffer
+
+  c := config{format: "text", recursive: true, include: []string{"[a-"}}
+
+  err := run([]string{"testdata"}, nil, &out, c)
+  if err == nil {
+    t.Fatal("Expected error. Got nil instead")
+  }
+
+  if !strings.Contains(err.Error(), "Invalid pattern") {
+    t.Errorf("Unexpected error message: %q", err)
+  }
+}
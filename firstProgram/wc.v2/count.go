@@ -0,0 +1,307 @@
+package wc
+
+import (
+  "bytes"
+  "io"
+  "unicode"
+  "unicode/utf8"
+)
+
+// bufSize is the size of the chunks read from the input
+const bufSize = 32 * 1024
+
+// Result holds every counter collected in a single pass over the input
+type Result struct {
+  Lines   int
+  Words   int
+  Bytes   int
+  Runes   int
+  MaxLine int
+  // bytes that are not valid UTF-8. They aren't counted as runes
+  Invalid int
+}
+
+// Options defines how the input is counted
+type Options struct {
+  // Tokenizer splits lines into words. When it's nil words are split on
+  // white space
+  Tokenizer Tokenizer
+}
+
+// Counter counts the bytes written to it, so it can be used anywhere an
+// io.Writer is expected, such as io.MultiWriter or io.TeeReader. It keeps
+// the state needed to count a stream delivered in chunks of any size.
+// Since it never holds more than a partial rune and, with a Tokenizer,
+// the current line between chunks, it has no limit on the length of
+// lines or words. The zero value is ready to use and splits words on
+// white space
+type Counter struct {
+  res     Result
+  inWord  bool
+  lineLen int
+  // used to merge the counters of adjacent ranges of the input: whether
+  // the first rune starts a word, whether a new line was found and the
+  // length of the text before the first new line
+  startsWord bool
+  newline    bool
+  head       int
+  // bytes of a rune split between two chunks
+  pending  [utf8.UTFMax]byte
+  npending int
+  // when tok is set, words are split by it instead of white space. It
+  // needs the current line and the words of the lines already split
+  tok   Tokenizer
+  line  []byte
+  words int
+}
+
+// Count reads r only once, collecting lines, words, bytes, runes and the
+// length of the longest line at the same time. It returns the counters
+// collected so far and the first read error, if any
+func Count(r io.Reader, opts Options) (Result, error) {
+  c := NewCounter(opts)
+  _, err := c.ReadFrom(r)
+
+  return c.Result(), err
+}
+
+// NewCounter returns a Counter using the given options
+func NewCounter(opts Options) *Counter {
+  return &Counter{tok: opts.Tokenizer}
+}
+
+// Write counts p. It never returns an error
+func (c *Counter) Write(p []byte) (int, error) {
+  c.write(p)
+  return len(p), nil
+}
+
+// ReadFrom counts everything read from r until EOF or an error. It
+// implements io.ReaderFrom so io.Copy uses it
+func (c *Counter) ReadFrom(r io.Reader) (int64, error) {
+  buf := make([]byte, bufSize)
+  total := int64(0)
+
+  for {
+    n, err := r.Read(buf)
+    c.write(buf[:n])
+    total += int64(n)
+
+    if err == io.EOF {
+      return total, nil
+    }
+
+    if err != nil {
+      return total, err
+    }
+  }
+}
+
+// Result returns the counters of everything written so far, including a
+// rune or a line left incomplete at the end. More bytes can still be
+// written after calling it
+func (c *Counter) Result() Result {
+  return c.result()
+}
+
+// write counts the chunk p
+func (c *Counter) write(p []byte) {
+  if c.tok != nil {
+    c.splitLines(p)
+  }
+
+  // Complete the rune split at the end of the previous chunk first
+  for c.npending > 0 && len(p) > 0 {
+    n := copy(c.pending[c.npending:], p)
+    buf := c.pending[:c.npending+n]
+
+    if !utf8.FullRune(buf) {
+      // Still incomplete, p was consumed entirely
+      c.npending += n
+      return
+    }
+
+    ru, size := utf8.DecodeRune(buf)
+    c.rune(ru, size)
+
+    if size < c.npending {
+      // An invalid sequence decodes as a single byte. The rest of
+      // the pending bytes must be decoded again
+      copy(c.pending[:], c.pending[size:c.npending])
+      c.npending -= size
+      continue
+    }
+
+    p = p[size-c.npending:]
+    c.npending = 0
+  }
+
+  for i := 0; i < len(p); {
+    // Fast path for ASCII
+    if p[i] < utf8.RuneSelf {
+      c.rune(rune(p[i]), 1)
+      i++
+      continue
+    }
+
+    if !utf8.FullRune(p[i:]) {
+      // Keep the start of the rune until the next chunk arrives
+      c.npending = copy(c.pending[:], p[i:])
+      return
+    }
+
+    ru, size := utf8.DecodeRune(p[i:])
+    c.rune(ru, size)
+    i += size
+  }
+}
+
+// splitLines splits the words of every line completed by p
+func (c *Counter) splitLines(p []byte) {
+  for len(p) > 0 {
+    i := bytes.IndexByte(p, '\n')
+    if i < 0 {
+      c.line = append(c.line, p...)
+      return
+    }
+
+    c.line = append(c.line, p[:i]...)
+    c.words += len(c.tok.Split(string(c.line)))
+    c.line = c.line[:0]
+    p = p[i+1:]
+  }
+}
+
+// rune counts a single decoded rune of size bytes
+func (c *Counter) rune(ru rune, size int) {
+  if c.res.Bytes == 0 {
+    c.startsWord = !unicode.IsSpace(ru)
+  }
+
+  c.res.Bytes += size
+
+  // An invalid byte decodes as RuneError of size 1. It still takes a
+  // position in the line and is part of a word
+  if ru == utf8.RuneError && size == 1 {
+    c.res.Invalid++
+  } else {
+    c.res.Runes++
+  }
+
+  if ru == '\n' {
+    if !c.newline {
+      c.newline = true
+      c.head = c.lineLen
+    }
+    c.res.Lines++
+    if c.lineLen > c.res.MaxLine {
+      c.res.MaxLine = c.lineLen
+    }
+    c.lineLen = 0
+  } else {
+    c.lineLen++
+  }
+
+  // A word starts at the first non space rune after a space
+  if unicode.IsSpace(ru) {
+    c.inWord = false
+  } else if !c.inWord {
+    c.inWord = true
+    c.res.Words++
+  }
+}
+
+// merge adds the counters of next, which counted the bytes that
+// immediately follow the ones counted by c. A word or a line crossing the
+// boundary between them is counted only once
+func (c *Counter) merge(next Counter) {
+  // A rune left incomplete at the end of a range is invalid since the
+  // following range always starts at the beginning of a rune. The range
+  // may hold nothing else
+  next.flush()
+
+  if next.res.Bytes == 0 {
+    return
+  }
+
+  if c.res.Bytes == 0 && c.npending == 0 {
+    *c = next
+    return
+  }
+
+  c.flush()
+
+  c.res.Lines += next.res.Lines
+  c.res.Bytes += next.res.Bytes
+  c.res.Runes += next.res.Runes
+  c.res.Invalid += next.res.Invalid
+  c.res.Words += next.res.Words
+
+  if c.inWord && next.startsWord {
+    c.res.Words--
+  }
+  c.inWord = next.inWord
+
+  if next.res.MaxLine > c.res.MaxLine {
+    c.res.MaxLine = next.res.MaxLine
+  }
+
+  if !next.newline {
+    c.lineLen += next.lineLen
+    return
+  }
+
+  if !c.newline {
+    c.newline = true
+    c.head = c.lineLen + next.head
+  }
+
+  if l := c.lineLen + next.head; l > c.res.MaxLine {
+    c.res.MaxLine = l
+  }
+  c.lineLen = next.lineLen
+}
+
+// flush counts the bytes of an incomplete rune as invalid
+func (c *Counter) flush() {
+  for c.npending > 0 {
+    ru, size := utf8.DecodeRune(c.pending[:c.npending])
+    c.rune(ru, size)
+    copy(c.pending[:], c.pending[size:c.npending])
+    c.npending -= size
+  }
+}
+
+// result works on a copy of the counter so the pending rune and the
+// last line can be counted without changing c
+func (c Counter) result() Result {
+  c.flush()
+
+  // The last line counts even if it's not terminated by a new line
+  if c.lineLen > 0 {
+    c.res.Lines++
+    if c.lineLen > c.res.MaxLine {
+      c.res.MaxLine = c.lineLen
+    }
+  }
+
+  if c.tok != nil {
+    c.res.Words = c.words + len(c.tok.Split(string(c.line)))
+  }
+
+  return c.res
+}
+
+// Add accumulates the counters of o into r. MaxLine keeps the longest
+// line of both results
+func (r *Result) Add(o Result) {
+  r.Lines += o.Lines
+  r.Words += o.Words
+  r.Bytes += o.Bytes
+  r.Runes += o.Runes
+  r.Invalid += o.Invalid
+
+  if o.MaxLine > r.MaxLine {
+    r.MaxLine = o.MaxLine
+  }
+}
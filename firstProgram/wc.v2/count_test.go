@@ -0,0 +1,176 @@
+package wc
+
+import (
+  "bytes"
+  "io"
+  "strings"
+  "testing"
+  "testing/iotest"
+)
+
+// TestCountWords tests Count set to count words
+func TestCountWords(t *testing.T) {
+  b := bytes.NewBufferString("word1 word2 word3 word4\n")
+
+  exp := 4
+
+  res, err := Count(b, Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res.Words != exp {
+    t.Errorf("Expected %d, got %d instead.\n", exp, res.Words)
+  }
+}
+
+// TestCountLines tests Count set to count lines
+func TestCountLines(t *testing.T) {
+  b := bytes.NewBufferString("word1 word2 word3\nline2\nline3 word1")
+
+  exp := 3
+
+  res, err := Count(b, Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res.Lines != exp {
+    t.Errorf("Expected %d, got %d instead.\n", exp, res.Lines)
+  }
+}
+
+// TestCountBytes tests Count set to count bytes
+func TestCountBytes(t *testing.T) {
+  b := bytes.NewBufferString("word1 word2 word3\nline2\nline3 word1")
+
+  exp := 35
+
+  res, err := Count(b, Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res.Bytes != exp {
+    t.Errorf("Expected %d, got %d instead.\n", exp, res.Bytes)
+  }
+}
+
+// TestCountAll tests that a single pass collects every counter
+func TestCountAll(t *testing.T) {
+  input := "héllo wörld\nsecond line here\n\tçà\n"
+  exp := Result{Lines: 3, Words: 6, Bytes: 37, Runes: 33, MaxLine: 16}
+
+  // Reading one byte at a time splits every multibyte rune in chunks
+  testCases := []struct {
+    name string
+    r    io.Reader
+  }{
+    {name: "SingleChunk", r: strings.NewReader(input)},
+    {name: "OneByteChunks",
+      r: iotest.OneByteReader(strings.NewReader(input))},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      res, err := Count(tc.r, Options{})
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if res != exp {
+        t.Errorf("Expected %+v, got %+v instead.\n", exp, res)
+      }
+    })
+  }
+}
+
+// TestCountInvalidUTF8 tests that invalid bytes are counted apart from
+// runes
+func TestCountInvalidUTF8(t *testing.T) {
+  // A valid three byte prefix interrupted by ASCII, a lone continuation
+  // byte and an incomplete rune at the end of the input
+  input := "a\xe2\x82b \x82 c\xe2\x82"
+  exp := Result{Lines: 1, Words: 3, Bytes: 10, Runes: 5, MaxLine: 10,
+    Invalid: 5}
+
+  res, err := Count(iotest.OneByteReader(strings.NewReader(input)), Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res != exp {
+    t.Errorf("Expected %+v, got %+v instead.\n", exp, res)
+  }
+}
+
+// TestCountLongLine tests lines longer than bufio.Scanner's token limit
+func TestCountLongLine(t *testing.T) {
+  line := strings.Repeat("x", 1024*1024)
+  input := line + "\n" + line + " y\n"
+  exp := Result{Lines: 2, Words: 3, Bytes: len(input), Runes: len(input),
+    MaxLine: len(line) + 2}
+
+  res, err := Count(strings.NewReader(input), Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res != exp {
+    t.Errorf("Expected %+v, got %+v instead.\n", exp, res)
+  }
+}
+
+// TestCountReadError tests that read errors are returned to the caller
+func TestCountReadError(t *testing.T) {
+  input := strings.Repeat("word ", bufSize)
+
+  res, err := Count(iotest.TimeoutReader(strings.NewReader(input)),
+    Options{})
+
+  if err != iotest.ErrTimeout {
+    t.Fatalf("Expected error %q, got %v instead", iotest.ErrTimeout, err)
+  }
+
+  // The counters read before the error are still returned
+  if res.Bytes != bufSize {
+    t.Errorf("Expected %d bytes, got %d instead.\n", bufSize, res.Bytes)
+  }
+}
+
+// TestCounterWriter tests that writes of any size through io.Writer give
+// the same result as Count and that Result doesn't stop the counting
+func TestCounterWriter(t *testing.T) {
+  input := "héllo wörld\nsecond line here\n\tçà"
+
+  exp, err := Count(strings.NewReader(input), Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var c Counter
+  var copied bytes.Buffer
+  w := io.MultiWriter(&c, &copied)
+
+  for i := 0; i < len(input); i += 3 {
+    end := i + 3
+    if end > len(input) {
+      end = len(input)
+    }
+
+    if _, err := io.WriteString(w, input[i:end]); err != nil {
+      t.Fatal(err)
+    }
+
+    // Reading the counters halfway must not change the final result
+    c.Result()
+  }
+
+  if res := c.Result(); res != exp {
+    t.Errorf("Expected %+v, got %+v instead.\n", exp, res)
+  }
+
+  if copied.String() != input {
+    t.Errorf("Expected %q copied, got %q instead", input, copied.String())
+  }
+}
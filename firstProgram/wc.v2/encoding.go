@@ -0,0 +1,177 @@
+package wc
+
+import (
+  "bufio"
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "unicode/utf16"
+  "unicode/utf8"
+)
+
+// Byte order marks found at the beginning of text files
+var (
+  bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+  bomUTF16LE = []byte{0xff, 0xfe}
+  bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// Decode returns a reader with the contents of r converted to UTF-8 from
+// encoding: utf-8, utf-16le, utf-16be or latin1. With auto, or an empty
+// encoding, it's detected from the byte order mark, assuming UTF-8
+// without one. The byte order mark is never part of the text returned. It
+// also reports whether the text differs from the bytes read from r
+func Decode(r io.Reader, encoding string) (io.Reader, bool, error) {
+  if encoding == "" {
+    encoding = "auto"
+  }
+
+  switch encoding {
+  case "latin1":
+    // Every byte is a valid character and there's no byte order mark
+    return &latin1Reader{r: r}, true, nil
+  case "auto", "utf-8", "utf-16le", "utf-16be":
+  default:
+    return nil, false, fmt.Errorf("Encoding not supported: %s", encoding)
+  }
+
+  br := bufio.NewReader(r)
+
+  head, err := br.Peek(len(bomUTF8))
+  if err != nil && err != io.EOF {
+    return nil, false, err
+  }
+
+  // A byte order mark is removed only when it matches the encoding
+  var bom []byte
+  for _, m := range []struct {
+    enc string
+    bom []byte
+  }{
+    {"utf-8", bomUTF8},
+    {"utf-16le", bomUTF16LE},
+    {"utf-16be", bomUTF16BE},
+  } {
+    if (encoding == "auto" || encoding == m.enc) &&
+      bytes.HasPrefix(head, m.bom) {
+      encoding, bom = m.enc, m.bom
+      break
+    }
+  }
+
+  if _, err := br.Discard(len(bom)); err != nil {
+    return nil, false, err
+  }
+
+  switch encoding {
+  case "utf-16le":
+    return &utf16Reader{r: br, order: binary.LittleEndian}, true, nil
+  case "utf-16be":
+    return &utf16Reader{r: br, order: binary.BigEndian}, true, nil
+  }
+
+  return br, len(bom) > 0, nil
+}
+
+// utf16Reader converts UTF-16 text read from r to UTF-8. Invalid
+// surrogates and a trailing odd byte are replaced by utf8.RuneError
+type utf16Reader struct {
+  r     io.Reader
+  order binary.ByteOrder
+  buf   []byte
+  // bytes read but not decoded yet and decoded bytes not returned yet
+  in  []byte
+  out []byte
+  err error
+}
+
+func (d *utf16Reader) Read(p []byte) (int, error) {
+  for len(d.out) == 0 {
+    if d.err != nil {
+      return 0, d.err
+    }
+    d.fill()
+  }
+
+  n := copy(p, d.out)
+  d.out = d.out[n:]
+
+  return n, nil
+}
+
+// fill decodes the next chunk read from r. A high surrogate at the end of
+// the chunk waits for the low surrogate in the next one
+func (d *utf16Reader) fill() {
+  if d.buf == nil {
+    d.buf = make([]byte, bufSize)
+  }
+
+  n, err := d.r.Read(d.buf)
+  d.in = append(d.in, d.buf[:n]...)
+  d.err = err
+
+  end := len(d.in) &^ 1
+  units := make([]uint16, 0, end/2)
+  for i := 0; i < end; i += 2 {
+    units = append(units, d.order.Uint16(d.in[i:]))
+  }
+
+  if l := len(units); d.err == nil && l > 0 &&
+    units[l-1] >= 0xd800 && units[l-1] < 0xdc00 {
+    units = units[:l-1]
+    end -= 2
+  }
+
+  d.out = d.out[:0]
+  for _, ru := range utf16.Decode(units) {
+    d.out = appendRune(d.out, ru)
+  }
+
+  d.in = append(d.in[:0], d.in[end:]...)
+
+  if d.err != nil && len(d.in) > 0 {
+    d.out = appendRune(d.out, utf8.RuneError)
+    d.in = d.in[:0]
+  }
+}
+
+// latin1Reader converts ISO 8859-1 text read from r to UTF-8, where every
+// byte is the code point of the same value
+type latin1Reader struct {
+  r   io.Reader
+  buf []byte
+  out []byte
+}
+
+func (d *latin1Reader) Read(p []byte) (int, error) {
+  if len(d.out) == 0 {
+    if d.buf == nil {
+      d.buf = make([]byte, bufSize)
+    }
+
+    n, err := d.r.Read(d.buf)
+
+    d.out = d.out[:0]
+    for _, b := range d.buf[:n] {
+      d.out = appendRune(d.out, rune(b))
+    }
+
+    if len(d.out) == 0 {
+      return 0, err
+    }
+  }
+
+  n := copy(p, d.out)
+  d.out = d.out[n:]
+
+  return n, nil
+}
+
+// appendRune appends the UTF-8 encoding of ru to p
+func appendRune(p []byte, ru rune) []byte {
+  var b [utf8.UTFMax]byte
+  n := utf8.EncodeRune(b[:], ru)
+
+  return append(p, b[:n]...)
+}
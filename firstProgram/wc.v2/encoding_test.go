@@ -0,0 +1,98 @@
+package wc
+
+import (
+  "bytes"
+  "encoding/binary"
+  "io/ioutil"
+  "strings"
+  "testing"
+  "testing/iotest"
+  "unicode/utf16"
+)
+
+// encodeUTF16 returns s encoded as UTF-16 in the given byte order
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+  units := utf16.Encode([]rune(s))
+  b := make([]byte, 2*len(units))
+
+  for i, u := range units {
+    order.PutUint16(b[2*i:], u)
+  }
+
+  return b
+}
+
+// TestDecode tests converting every supported encoding to UTF-8
+func TestDecode(t *testing.T) {
+  text := "héllo 𝄞 wörld\n"
+  le := encodeUTF16(text, binary.LittleEndian)
+  be := encodeUTF16(text, binary.BigEndian)
+
+  testCases := []struct {
+    name     string
+    encoding string
+    input    []byte
+    exp      string
+    expConv  bool
+  }{
+    {name: "UTF8", encoding: "auto", input: []byte(text), exp: text},
+    {name: "UTF8BOM", encoding: "auto",
+      input: append([]byte("\xef\xbb\xbf"), text...), exp: text,
+      expConv: true},
+    {name: "UTF16LEBOM", encoding: "auto",
+      input: append([]byte{0xff, 0xfe}, le...), exp: text, expConv: true},
+    {name: "UTF16BEBOM", encoding: "auto",
+      input: append([]byte{0xfe, 0xff}, be...), exp: text, expConv: true},
+    {name: "UTF16LE", encoding: "utf-16le", input: le, exp: text,
+      expConv: true},
+    {name: "UTF16BE", encoding: "utf-16be", input: be, exp: text,
+      expConv: true},
+    {name: "UTF16OddByte", encoding: "utf-16le",
+      input: append(encodeUTF16("ab", binary.LittleEndian), 'c'),
+      exp:   "ab�", expConv: true},
+    {name: "UTF16LoneSurrogate", encoding: "utf-16be",
+      input: []byte{0xd8, 0x34, 0x00, 0x61}, exp: "�a",
+      expConv: true},
+    {name: "Latin1", encoding: "latin1", input: []byte("h\xe9llo \xff"),
+      exp: "héllo ÿ", expConv: true},
+    {name: "ExplicitUTF8", encoding: "utf-8", input: []byte("\xff\xfeab"),
+      exp: "\xff\xfeab"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      // Reading one byte at a time splits units and surrogate pairs
+      r := iotest.OneByteReader(bytes.NewReader(tc.input))
+
+      dr, conv, err := Decode(r, tc.encoding)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      res, err := ioutil.ReadAll(dr)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if string(res) != tc.exp {
+        t.Errorf("Expected %q, got %q instead", tc.exp, res)
+      }
+
+      if conv != tc.expConv {
+        t.Errorf("Expected converted %t, got %t instead", tc.expConv, conv)
+      }
+    })
+  }
+}
+
+// TestDecodeUnsupported tests an unknown encoding
+func TestDecodeUnsupported(t *testing.T) {
+  _, _, err := Decode(strings.NewReader(""), "ebcdic")
+  if err == nil {
+    t.Fatalf("Expected error. Got nil instead")
+  }
+
+  if !strings.Contains(err.Error(), "Encoding not supported: ebcdic") {
+    t.Errorf("Unexpected error message: %q", err)
+  }
+}
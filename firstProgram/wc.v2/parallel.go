@@ -0,0 +1,89 @@
+package wc
+
+import (
+  "io"
+  "sync"
+  "unicode/utf8"
+)
+
+// CountParallel splits the first size bytes of r into jobs ranges, counts
+// each range in its own goroutine and merges the results. Ranges always
+// start at the beginning of a rune so for valid UTF-8 the result is the
+// same as counting sequentially. Words are always split on white space
+func CountParallel(r io.ReaderAt, size int64, jobs int) (Result, error) {
+  if jobs < 1 {
+    jobs = 1
+  }
+
+  // Calculate the boundaries of every range
+  offsets := make([]int64, jobs+1)
+  offsets[jobs] = size
+  for i := 1; i < jobs; i++ {
+    off, err := runeStart(r, size*int64(i)/int64(jobs), size)
+    if err != nil {
+      return Result{}, err
+    }
+
+    // Boundaries must not go backwards after moving to a rune start
+    if off < offsets[i-1] {
+      off = offsets[i-1]
+    }
+    offsets[i] = off
+  }
+
+  counters := make([]Counter, jobs)
+  errs := make([]error, jobs)
+
+  wg := sync.WaitGroup{}
+
+  for i := 0; i < jobs; i++ {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      sr := io.NewSectionReader(r, offsets[i], offsets[i+1]-offsets[i])
+      _, errs[i] = counters[i].ReadFrom(sr)
+    }(i)
+  }
+
+  wg.Wait()
+
+  // Merge the ranges in order since words and lines may cross the
+  // boundaries between them
+  total := Counter{}
+  for i := range counters {
+    if errs[i] != nil {
+      return total.result(), errs[i]
+    }
+    total.merge(counters[i])
+  }
+
+  return total.result(), nil
+}
+
+// runeStart returns the first offset at or after off that starts a rune.
+// It skips at most the continuation bytes of a single rune
+func runeStart(r io.ReaderAt, off, size int64) (int64, error) {
+  buf := make([]byte, utf8.UTFMax-1)
+
+  n, err := r.ReadAt(buf, off)
+  if err != nil && err != io.EOF {
+    return 0, err
+  }
+
+  for i := 0; i < n; i++ {
+    if utf8.RuneStart(buf[i]) {
+      off += int64(i)
+      break
+    }
+
+    if i == n-1 {
+      off += int64(n)
+    }
+  }
+
+  if off > size {
+    return size, nil
+  }
+
+  return off, nil
+}
@@ -0,0 +1,44 @@
+package wc
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+// TestCountParallel tests that counting ranges in parallel gives the same
+// result as counting sequentially, regardless of where the boundaries fall
+func TestCountParallel(t *testing.T) {
+  inputs := map[string]string{
+    "ASCII": strings.Repeat("word1 word2\nlonger line of words\n", 50),
+    "MultiByte": strings.Repeat("héllo wörld ✓ 日本語のテキスト\n", 40) +
+      "unterminated 𝄞𝄞",
+    "LongWords":  strings.Repeat("x", 997) + " " + strings.Repeat("ü", 503),
+    "SpacesOnly": strings.Repeat(" \t\n", 100),
+    "Invalid":    strings.Repeat("a\xe2\x82b \x82 ü\xff\n", 30),
+    "TruncatedEnd": strings.Repeat("a", 99) + "\xe2" + strings.Repeat("b", 100) +
+      "\xe2\x82",
+    "Empty": "",
+  }
+
+  for name, input := range inputs {
+    exp, err := Count(strings.NewReader(input), Options{})
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    for jobs := 1; jobs <= 17; jobs++ {
+      r := bytes.NewReader([]byte(input))
+
+      res, err := CountParallel(r, int64(len(input)), jobs)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if res != exp {
+        t.Errorf("%s with %d jobs: expected %+v, got %+v instead.",
+          name, jobs, exp, res)
+      }
+    }
+  }
+}
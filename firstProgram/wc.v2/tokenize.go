@@ -0,0 +1,256 @@
+package wc
+
+import (
+  "fmt"
+  "regexp"
+  "strings"
+  "unicode"
+)
+
+// Tokenizer splits a line of text into words. Words never span more than
+// one line
+type Tokenizer interface {
+  Split(line string) []string
+}
+
+// NewTokenizer returns the tokenizer with the given name: whitespace,
+// regex, unicode or ident. The regex tokenizer splits words on matches of
+// the regular expression sep. The whitespace tokenizer is returned as nil
+// since Counter handles it without splitting lines
+func NewTokenizer(name, sep string) (Tokenizer, error) {
+  switch name {
+  case "whitespace":
+    return nil, nil
+  case "regex":
+    if sep == "" {
+      return nil, fmt.Errorf("Separator is required by the regex tokenizer")
+    }
+
+    re, err := regexp.Compile(sep)
+    if err != nil {
+      return nil, fmt.Errorf("Invalid separator: %s", err)
+    }
+
+    return regexTokenizer{sep: re}, nil
+  case "unicode":
+    return unicodeTokenizer{}, nil
+  case "ident":
+    return identTokenizer{}, nil
+  }
+
+  return nil, fmt.Errorf("Tokenizer not supported: %s", name)
+}
+
+// regexTokenizer splits words on matches of a regular expression, like
+// the fields of CSV records
+type regexTokenizer struct {
+  sep *regexp.Regexp
+}
+
+func (t regexTokenizer) Split(line string) []string {
+  line = strings.TrimRight(line, "\r\n")
+  if line == "" {
+    return nil
+  }
+
+  words := []string{}
+  for _, w := range t.sep.Split(line, -1) {
+    if w != "" {
+      words = append(words, w)
+    }
+  }
+
+  return words
+}
+
+// identTokenizer splits identifiers in source code into the words they're
+// made of. Anything other than letters and digits separates identifiers,
+// including underscores, and identifiers are split on camelCase humps:
+// "parseHTTPRequest_v2" gives "parse", "HTTP", "Request" and "v2"
+type identTokenizer struct{}
+
+func (identTokenizer) Split(line string) []string {
+  words := []string{}
+
+  ids := strings.FieldsFunc(line, func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+  })
+
+  for _, id := range ids {
+    words = append(words, splitCamel(id)...)
+  }
+
+  return words
+}
+
+// splitCamel splits id before every upper case letter that follows a
+// lower case letter or a digit, and before the last letter of a run of
+// upper case letters followed by a lower case one
+func splitCamel(id string) []string {
+  runes := []rune(id)
+  words := []string{}
+  start := 0
+
+  for i := 1; i < len(runes); i++ {
+    prev, cur := runes[i-1], runes[i]
+
+    if !unicode.IsUpper(cur) {
+      continue
+    }
+
+    if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+      (unicode.IsUpper(prev) && i+1 < len(runes) &&
+        unicode.IsLower(runes[i+1])) {
+      words = append(words, string(runes[start:i]))
+      start = i
+    }
+  }
+
+  return append(words, string(runes[start:]))
+}
+
+// wordBreak is the word break property of a rune as defined by UAX #29,
+// limited to the values used by unicodeTokenizer
+type wordBreak int
+
+const (
+  wbOther wordBreak = iota
+  wbLetter
+  wbNumeric
+  wbKatakana
+  // Ideographs and Hiragana have no rule keeping them together
+  wbIdeographic
+  wbExtend
+  wbExtendNumLet
+  wbMidLetter
+  wbMidNum
+  wbMidNumLet
+)
+
+// wordBreakOf returns the word break property of r
+func wordBreakOf(r rune) wordBreak {
+  switch {
+  case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r):
+    return wbIdeographic
+  case unicode.Is(unicode.Katakana, r) || r == '\u30fc':
+    return wbKatakana
+  case unicode.IsLetter(r):
+    return wbLetter
+  case unicode.IsDigit(r):
+    return wbNumeric
+  case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+    return wbExtend
+  case unicode.Is(unicode.Pc, r):
+    return wbExtendNumLet
+  case r == ':' || r == '\u00b7' || r == '\u05f4' || r == '\u2027' ||
+    r == '\ufe13' || r == '\ufe55' || r == '\uff1a':
+    return wbMidLetter
+  case r == ',' || r == ';' || r == '\u037e' || r == '\u066c' ||
+    r == '\ufe10' || r == '\ufe14' || r == '\uff0c' || r == '\uff1b':
+    return wbMidNum
+  case r == '.' || r == '\'' || r == '\u2018' || r == '\u2019' ||
+    r == '\u2024' || r == '\ufe52' || r == '\uff07' || r == '\uff0e':
+    return wbMidNumLet
+  }
+
+  return wbOther
+}
+
+// unicodeTokenizer finds words following the main word boundary rules of
+// UAX #29: letters and digits stay together with the punctuation allowed
+// between them, as in "can't" or "3.14", Katakana runs form a single word
+// and every ideograph or Hiragana character is a word on its own, so text
+// in Chinese or Japanese is counted without spaces. Only segments with
+// letters, digits or ideographs are words. Scripts that need a dictionary
+// to find word boundaries, like Thai, aren't segmented
+type unicodeTokenizer struct{}
+
+func (unicodeTokenizer) Split(line string) []string {
+  runes := []rune(line)
+  words := []string{}
+
+  start := -1
+  // the last property in the current word, ignoring Extend
+  last := wbOther
+
+  end := func(i int) {
+    if start >= 0 {
+      words = append(words, string(runes[start:i]))
+    }
+    start, last = -1, wbOther
+  }
+
+  for i := 0; i < len(runes); i++ {
+    wb := wordBreakOf(runes[i])
+
+    switch wb {
+    case wbLetter, wbNumeric:
+      if last != wbLetter && last != wbNumeric && last != wbExtendNumLet {
+        end(i)
+        start = i
+      }
+    case wbKatakana:
+      if last != wbKatakana && last != wbExtendNumLet {
+        end(i)
+        start = i
+      }
+    case wbIdeographic:
+      end(i)
+      start = i
+    case wbExtend:
+      // Marks belong to the rune before them
+      if start >= 0 {
+        continue
+      }
+    case wbExtendNumLet:
+      if last != wbLetter && last != wbNumeric && last != wbKatakana &&
+        last != wbExtendNumLet {
+        end(i)
+        start = i
+      }
+    case wbMidLetter, wbMidNum, wbMidNumLet:
+      // Keep the punctuation only between two letters or two digits
+      if i+1 < len(runes) && joins(last, wb, wordBreakOf(runes[i+1])) {
+        continue
+      }
+      end(i)
+      continue
+    default:
+      end(i)
+      continue
+    }
+
+    last = wb
+  }
+  end(len(runes))
+
+  // Segments made only of connector punctuation are not words
+  result := words[:0]
+  for _, w := range words {
+    if strings.IndexFunc(w, func(r rune) bool {
+      return unicode.IsLetter(r) || unicode.IsDigit(r)
+    }) >= 0 {
+      result = append(result, w)
+    }
+  }
+
+  return result
+}
+
+// joins reports whether the punctuation mid keeps the runes before and
+// after it in the same word
+func joins(before, mid, after wordBreak) bool {
+  letters := before == wbLetter && after == wbLetter
+  numbers := before == wbNumeric && after == wbNumeric
+
+  switch mid {
+  case wbMidLetter:
+    return letters
+  case wbMidNum:
+    return numbers
+  case wbMidNumLet:
+    return letters || numbers
+  }
+
+  return false
+}
@@ -0,0 +1,110 @@
+package wc
+
+import (
+  "reflect"
+  "strings"
+  "testing"
+  "testing/iotest"
+)
+
+// TestTokenizers tests the words found by every tokenizer
+func TestTokenizers(t *testing.T) {
+  testCases := []struct {
+    name string
+    tok  string
+    sep  string
+    line string
+    exp  []string
+  }{
+    {name: "Whitespace", tok: "whitespace", line: "a  b\tc\n",
+      exp: []string{"a", "b", "c"}},
+    {name: "RegexCSV", tok: "regex", sep: ",", line: "id,,name,value\n",
+      exp: []string{"id", "name", "value"}},
+    {name: "RegexMultiChar", tok: "regex", sep: `\s*[;|]\s*`,
+      line: "one ; two|three  | four", exp: []string{"one", "two",
+        "three", "four"}},
+    {name: "UnicodeLatin", tok: "unicode",
+      line: "Hello, world! can't stop 3.14 or 1,000 _x_ (end).",
+      exp: []string{"Hello", "world", "can't", "stop", "3.14", "or",
+        "1,000", "_x_", "end"}},
+    {name: "UnicodeChinese", tok: "unicode", line: "这是中文。",
+      exp: []string{"这", "是", "中", "文"}},
+    {name: "UnicodeJapanese", tok: "unicode", line: "日本語のテキストです",
+      exp: []string{"日", "本", "語", "の", "テキスト", "で", "す"}},
+    {name: "UnicodeMixed", tok: "unicode", line: "Go言語 v1.12",
+      exp: []string{"Go", "言", "語", "v1.12"}},
+    {name: "UnicodeMarks", tok: "unicode", line: "café näive",
+      exp: []string{"café", "näive"}},
+    {name: "Ident", tok: "ident",
+      line: "func parseHTTPRequest_v2(max_len int) utf8Decode",
+      exp: []string{"func", "parse", "HTTP", "Request", "v2", "max",
+        "len", "int", "utf8", "Decode"}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      tok, err := NewTokenizer(tc.tok, tc.sep)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // The whitespace tokenizer is handled by the counter itself
+      res := strings.Fields(tc.line)
+      if tok != nil {
+        res = tok.Split(tc.line)
+      }
+
+      if !reflect.DeepEqual(res, tc.exp) {
+        t.Errorf("Expected %q, got %q instead", tc.exp, res)
+      }
+    })
+  }
+}
+
+// TestNewTokenizerErrors tests invalid tokenizer options
+func TestNewTokenizerErrors(t *testing.T) {
+  testCases := []struct {
+    tok    string
+    sep    string
+    errMsg string
+  }{
+    {tok: "regex", errMsg: "Separator is required"},
+    {tok: "regex", sep: "[", errMsg: "Invalid separator"},
+    {tok: "bigram", errMsg: "Tokenizer not supported: bigram"},
+  }
+
+  for _, tc := range testCases {
+    _, err := NewTokenizer(tc.tok, tc.sep)
+    if err == nil {
+      t.Fatalf("Expected error. Got nil instead")
+    }
+
+    if !strings.Contains(err.Error(), tc.errMsg) {
+      t.Errorf("Unexpected error message: %q", err)
+    }
+  }
+}
+
+// TestCountWithTokenizer tests that lines split between chunks are
+// tokenized as a whole while the other counters don't change
+func TestCountWithTokenizer(t *testing.T) {
+  input := "日本語のテキスト\nsnake_case camelCase\nlast"
+
+  exp, err := Count(strings.NewReader(input), Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  exp.Words = 8
+
+  tok, _ := NewTokenizer("unicode", "")
+
+  res, err := Count(iotest.OneByteReader(strings.NewReader(input)),
+    Options{Tokenizer: tok})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if res != exp {
+    t.Errorf("Expected %+v, got %+v instead", exp, res)
+  }
+}
@@ -9,6 +9,7 @@ import (
   "os"
 
   "strings"
+  "time"
 
   "pragprog.com/rggo/interacting/todo"
 )
@@ -16,18 +17,52 @@ import (
 // Default file name
 var todoFileName = ".todo.json"
 
+// Number of changes listed by -history
+const historySize = 20
+
 func main() {
   // Parsing command line flags
   add := flag.Bool("add", false, "Add task to the ToDo list")
   list := flag.Bool("list", false, "List all tasks")
-  complete := flag.Int("complete", 0, "Item to be completed")
-  del := flag.Int("del", 0, "Item to be deleted")
+  complete := flag.String("complete", "",
+    "ID or path, like 3.2, of the item to be completed")
+  del := flag.String("del", "",
+    "ID or path of the item to be deleted with its subtasks")
+  change := flag.String("change", "", "ID or path of the item to change")
+  parent := flag.String("parent", "",
+    "ID or path of the item to add the new item as a subtask of")
+  cascade := flag.Bool("cascade", false,
+    "Complete the subtasks of the completed item too")
+  rollUp := flag.Bool("rollup", false,
+    "Complete the parent of the completed item once all its subtasks are done")
+  priority := flag.String("priority", "",
+    "Priority of the item to add or change: A-Z or 1-5, none to remove it")
+  dueDate := flag.String("due-date", "",
+    "Due date of the item to add or change: YYYY-MM-DD, none to remove it")
+  recur := flag.String("recur", "",
+    "Recurrence of the item to add or change: daily, weekly[:mon,...], "+
+      "monthly[:N], every:N days after completion, none to remove it")
+  sortBy := flag.String("sort", "", "Sort the list by priority or due")
+  due := flag.String("due", "", "List items due today, this week or overdue")
+  project := flag.String("project", "", "List items of the +project")
+  context := flag.String("context", "", "List items of the @context")
+  tag := flag.String("tag", "", "List items with the #tag")
+  names := flag.Bool("names", false,
+    "List projects, contexts and tags with their open and done items")
+  undo := flag.Bool("undo", false, "Undo the last change")
+  redo := flag.Bool("redo", false, "Redo the last change undone")
+  history := flag.Bool("history", false, "List the recent changes")
+  addr := flag.String("addr", "localhost:8080",
+    "Address to serve the list on with the serve command")
 
   flag.Usage = func() {
     fmt.Fprintf(flag.CommandLine.Output(),
       "%s tool. Developed for The Pragmatic Bookshelf\n", os.Args[0])
     fmt.Fprintf(flag.CommandLine.Output(), "Copyright 2020\n")
     fmt.Fprintln(flag.CommandLine.Output(), "Usage information:")
+    fmt.Fprintf(flag.CommandLine.Output(),
+      "  %s serve [-addr host:port]\n\tServe the list as a JSON REST API\n",
+      os.Args[0])
     flag.PrintDefaults()
   }
 
@@ -38,39 +73,170 @@ func main() {
     todoFileName = os.Getenv("TODO_FILENAME")
   }
 
+  // The storage backend is chosen by the file extension unless the user
+  // defined the ENV VAR for a custom one
+  store, err := todo.NewStore(todoFileName, os.Getenv("TODO_BACKEND"))
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+
+  // The server locks the list on every request instead of holding the
+  // lock until it ends
+  if !*add && flag.Arg(0) == "serve" {
+    // Parsing stops at the command, so the flags following it are parsed
+    // on their own
+    serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+    serveFlags.StringVar(addr, "addr", *addr, "Address to serve the list on")
+    serveFlags.Parse(flag.Args()[1:])
+
+    if serveFlags.NArg() > 0 {
+      fmt.Fprintf(os.Stderr, "Unexpected arguments after serve: %s\n",
+        strings.Join(serveFlags.Args(), " "))
+      os.Exit(1)
+    }
+
+    fmt.Fprintf(os.Stderr, "Serving %s on %s\n", todoFileName, *addr)
+    if err := serve(*addr, todoFileName, store); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    return
+  }
+
+  // Hold the lock until the program ends so concurrent invocations don't
+  // lose each other's changes. The system also releases it when the
+  // program exits, even through os.Exit or a crash
+  lock, err := todo.Lock(todoFileName)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  defer lock.Unlock()
+
   // Define an items list
   l := &todo.List{}
 
-  // Use the Get method to read to do items from file
-  if err := l.Get(todoFileName); err != nil {
+  // Use the GetFrom method to read to do items from the store
+  if err := l.GetFrom(store); err != nil {
     fmt.Fprintln(os.Stderr, err)
     os.Exit(1)
   }
 
+  // Every change is recorded in a journal next to the list, comparing the
+  // items before and after it
+  journal := todo.NewJournal(todoFileName)
+  before := append(todo.List{}, *l...)
+
   // Decide what to do based on the provided flags
   switch {
   case *list:
+    // Filter and sort a copy of the items without saving it
+    filtered := l.FilterBy(*project, *context, *tag)
+    l = &filtered
+
+    if *due != "" {
+      filtered, err := l.FilterDue(*due, time.Now())
+      if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+      }
+      l = &filtered
+    }
+
+    if *sortBy != "" {
+      if err := l.Sort(*sortBy); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+      }
+    }
+
     // List current to do items
     fmt.Print(l)
-  case *complete > 0:
-    // Complete the given item
-    if err := l.Complete(*complete); err != nil {
+  case *names:
+    // List every project, context and tag
+    for _, n := range l.Names() {
+      fmt.Printf("%s: %d open, %d done\n", n.Name, n.Open, n.Done)
+    }
+  case *history:
+    // List the recent changes, oldest first
+    entries, err := journal.History(historySize)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    for _, e := range entries {
+      fmt.Println(e)
+    }
+  case *undo || *redo:
+    // Undo or redo the last change and print it
+    replay := journal.Undo
+    if *redo {
+      replay = journal.Redo
+    }
+
+    e, err := replay(l)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    // Save the new list before recording it in the journal
+    if err := l.SaveTo(store); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    if err := journal.Append(e); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    fmt.Println(e)
+  case *complete != "":
+    // Complete the given item, its subtasks and parents as requested
+    id, err := l.Resolve(*complete)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    opts := todo.CompleteOptions{Cascade: *cascade, RollUp: *rollUp}
+    if err := l.CompleteWith(id, opts); err != nil {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
-    // Save the new list
-    if err := l.Save(todoFileName); err != nil {
+    // Save the new list and record the change in the journal
+    if err := save(store, journal, "complete", before, l); err != nil {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
-  case *del > 0:
+  case *del != "":
     // Delete the given item
-    if err := l.Delete(*del); err != nil {
+    id, err := l.Resolve(*del)
+    if err != nil {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
-    // Save the new list
-    if err := l.Save(todoFileName); err != nil {
+    if err := l.Delete(id); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    // Save the new list and record the change in the journal
+    if err := save(store, journal, "delete", before, l); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  case *change != "":
+    // Change the priority, due date and recurrence of the given item
+    id, err := l.Resolve(*change)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    if err := setAttributes(l, id, *priority, *dueDate,
+      *recur); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    // Save the new list and record the change in the journal
+    if err := save(store, journal, "change", before, l); err != nil {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
@@ -82,9 +248,19 @@ func main() {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
-    l.Add(t)
-    // Save the new list
-    if err := l.Save(todoFileName); err != nil {
+    if err := addTask(l, *parent, t); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    // The new item is the last one
+    id := (*l)[len(*l)-1].ID
+    if err := setAttributes(l, id, *priority, *dueDate,
+      *recur); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    // Save the new list and record the change in the journal
+    if err := save(store, journal, "add", before, l); err != nil {
       fmt.Fprintln(os.Stderr, err)
       os.Exit(1)
     }
@@ -95,6 +271,78 @@ func main() {
   }
 }
 
+// save saves the list in the store and records the operation op, that
+// changed the items in before, in the journal
+func save(s todo.Store, j *todo.Journal, op string, before todo.List,
+  l *todo.List) error {
+
+  if err := l.SaveTo(s); err != nil {
+    return err
+  }
+
+  return j.Record(op, before, *l)
+}
+
+// addTask adds the task to the list, as a subtask of the item at the
+// path parent unless it's empty
+func addTask(l *todo.List, parent, task string) error {
+  if parent == "" {
+    l.Add(task)
+    return nil
+  }
+
+  id, err := l.Resolve(parent)
+  if err != nil {
+    return err
+  }
+
+  return l.AddSub(id, task)
+}
+
+// setAttributes sets the priority, due date and recurrence of the item
+// with the given ID. Empty values are left unchanged and "none" removes
+// them
+func setAttributes(l *todo.List, id int, priority, dueDate,
+  recur string) error {
+
+  if priority != "" {
+    if priority == "none" {
+      priority = ""
+    }
+
+    if err := l.SetPriority(id, priority); err != nil {
+      return err
+    }
+  }
+
+  if dueDate != "" {
+    var due time.Time
+    if dueDate != "none" {
+      var err error
+      due, err = time.ParseInLocation(todo.DateFormat, dueDate, time.Local)
+      if err != nil {
+        return fmt.Errorf("Invalid due date %q: use YYYY-MM-DD", dueDate)
+      }
+    }
+
+    if err := l.SetDue(id, due); err != nil {
+      return err
+    }
+  }
+
+  if recur != "" {
+    if recur == "none" {
+      recur = ""
+    }
+
+    if err := l.SetRecur(id, recur); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
 // getTask function decides where to get the description for a new
 // task from: arguments or STDIN
 func getTask(r io.Reader, args ...string) (string, error) {
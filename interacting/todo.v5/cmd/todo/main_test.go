@@ -6,9 +6,14 @@ import (
   "runtime"
 
   "io"
+  "io/ioutil"
+  "net"
+  "net/http"
   "os"
   "os/exec"
+  "strings"
   "testing"
+  "time"
 )
 
 var (
@@ -37,6 +42,8 @@ func TestMain(m *testing.M) {
   fmt.Println("Cleaning up...")
   os.Remove(binName)
   os.Remove(fileName)
+  os.Remove(fileName + ".lock")
+  os.Remove(fileName + ".journal")
 
   os.Exit(result)
 }
@@ -107,4 +114,426 @@ func TestTodoCLI(t *testing.T) {
       t.Errorf("Expected %q, got %q instead\n", expected, string(out))
     }
   })
+
+  task3 := "test task number 3"
+  t.Run("CompleteTaskByID", func(t *testing.T) {
+    if err := exec.Command(cmdPath, "-add", task3).Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    // Deleting the first item must not change the ID of the others
+    if err := exec.Command(cmdPath, "-del", "1").Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    if err := exec.Command(cmdPath, "-complete", "3").Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    cmd := exec.Command(cmdPath, "-list")
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := fmt.Sprintf("X 3: %s\n", task3)
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("PriorityAndDueDate", func(t *testing.T) {
+    due := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+    cmd := exec.Command(cmdPath, "-add", "-priority", "b", "-due-date", due,
+      "test task number 4")
+    if err := cmd.Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    cmd = exec.Command(cmdPath, "-change", "3", "-priority", "A")
+    if err := cmd.Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    cmd = exec.Command(cmdPath, "-list", "-sort", "priority")
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := fmt.Sprintf("X 3: (A) %s\n  4: (B) test task number 4 (due %s)\n",
+      task3, due)
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+
+    cmd = exec.Command(cmdPath, "-list", "-due", "week")
+    out, err = cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected = fmt.Sprintf("  4: (B) test task number 4 (due %s)\n", due)
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("ProjectsAndTags", func(t *testing.T) {
+    for _, task := range []string{"Buy milk +home @shop",
+      "Send invoice +work #billing"} {
+      if err := exec.Command(cmdPath, "-add", task).Run(); err != nil {
+        t.Fatal(err)
+      }
+    }
+
+    cmd := exec.Command(cmdPath, "-list", "-project", "work")
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := "  6: Send invoice +work #billing\n"
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+
+    cmd = exec.Command(cmdPath, "-names")
+    out, err = cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected = "+home: 1 open, 0 done\n+work: 1 open, 0 done\n" +
+      "@shop: 1 open, 0 done\n#billing: 1 open, 0 done\n"
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("TodoTxtBackend", func(t *testing.T) {
+    txtFile := ".todo.list"
+    defer os.Remove(txtFile)
+    defer os.Remove(txtFile + ".lock")
+    defer os.Remove(txtFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+txtFile,
+      "TODO_BACKEND=todotxt")
+
+    cmd := exec.Command(cmdPath, "-add", "-priority", "A", task)
+    cmd.Env = env
+    if err := cmd.Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    saved, err := ioutil.ReadFile(txtFile)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := fmt.Sprintf("(A) %s %s id:1\n",
+      time.Now().Format("2006-01-02"), task)
+
+    if expected != string(saved) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(saved))
+    }
+  })
+
+  t.Run("ConcurrentAdds", func(t *testing.T) {
+    lockFile := ".todo.concurrent.json"
+    defer os.Remove(lockFile)
+    defer os.Remove(lockFile + ".lock")
+    defer os.Remove(lockFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+lockFile)
+    adds := 20
+
+    errCh := make(chan error, adds)
+    for i := 0; i < adds; i++ {
+      cmd := exec.Command(cmdPath, "-add", fmt.Sprintf("concurrent %d", i))
+      cmd.Env = env
+
+      go func() {
+        errCh <- cmd.Run()
+      }()
+    }
+
+    for i := 0; i < adds; i++ {
+      if err := <-errCh; err != nil {
+        t.Fatal(err)
+      }
+    }
+
+    cmd := exec.Command(cmdPath, "-list")
+    cmd.Env = env
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if lines := strings.Count(string(out), "\n"); lines != adds {
+      t.Errorf("Expected %d items, got %d instead\n", adds, lines)
+    }
+  })
+
+  t.Run("UndoRedo", func(t *testing.T) {
+    undoFile := ".todo.undo.json"
+    defer os.Remove(undoFile)
+    defer os.Remove(undoFile + ".lock")
+    defer os.Remove(undoFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+undoFile)
+
+    for _, args := range [][]string{{"-add", "first"}, {"-add", "second"},
+      {"-del", "1"}} {
+      cmd := exec.Command(cmdPath, args...)
+      cmd.Env = env
+      if err := cmd.Run(); err != nil {
+        t.Fatal(err)
+      }
+    }
+
+    list := func() string {
+      cmd := exec.Command(cmdPath, "-list")
+      cmd.Env = env
+      out, err := cmd.CombinedOutput()
+      if err != nil {
+        t.Fatal(err)
+      }
+      return string(out)
+    }
+
+    cmd := exec.Command(cmdPath, "-undo")
+    cmd.Env = env
+    if err := cmd.Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    expected := "  1: first\n  2: second\n"
+    if out := list(); expected != out {
+      t.Errorf("Expected %q, got %q instead\n", expected, out)
+    }
+
+    cmd = exec.Command(cmdPath, "-redo")
+    cmd.Env = env
+    if err := cmd.Run(); err != nil {
+      t.Fatal(err)
+    }
+
+    expected = "  2: second\n"
+    if out := list(); expected != out {
+      t.Errorf("Expected %q, got %q instead\n", expected, out)
+    }
+
+    cmd = exec.Command(cmdPath, "-redo")
+    cmd.Env = env
+    out, err := cmd.CombinedOutput()
+    if err == nil {
+      t.Fatal("Expected error. Got nil instead")
+    }
+
+    if !strings.Contains(string(out), "Nothing to redo") {
+      t.Errorf("Unexpected error message: %q", string(out))
+    }
+
+    cmd = exec.Command(cmdPath, "-history")
+    cmd.Env = env
+    out, err = cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    ops := []string{"#1 add: 1 first", "#2 add: 2 second", "#3 delete: 1 first",
+      "#4 undo #3: 1 first", "#5 redo #3: 1 first"}
+    lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+    if len(lines) != len(ops) {
+      t.Fatalf("Expected %d entries, got %q instead", len(ops), string(out))
+    }
+    for i, op := range ops {
+      if !strings.HasSuffix(lines[i], op) {
+        t.Errorf("Expected entry ending with %q, got %q instead", op,
+          lines[i])
+      }
+    }
+  })
+
+  t.Run("RecurringTask", func(t *testing.T) {
+    recurFile := ".todo.recur.json"
+    defer os.Remove(recurFile)
+    defer os.Remove(recurFile + ".lock")
+    defer os.Remove(recurFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+recurFile)
+
+    for _, args := range [][]string{
+      {"-add", "-due-date", "2100-01-04", "-recur", "weekly:mon,thu",
+        "Rotate", "keys"},
+      {"-complete", "1"},
+    } {
+      cmd := exec.Command(cmdPath, args...)
+      cmd.Env = env
+      if err := cmd.Run(); err != nil {
+        t.Fatal(err)
+      }
+    }
+
+    cmd := exec.Command(cmdPath, "-list")
+    cmd.Env = env
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := "X 1: Rotate keys (due 2100-01-04) (repeats weekly on mon,thu)\n" +
+      "  2: Rotate keys (due 2100-01-07) (repeats weekly on mon,thu)\n"
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("Subtasks", func(t *testing.T) {
+    treeFile := ".todo.tree.json"
+    defer os.Remove(treeFile)
+    defer os.Remove(treeFile + ".lock")
+    defer os.Remove(treeFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+treeFile)
+
+    for _, args := range [][]string{
+      {"-add", "Plan", "trip"},
+      {"-add", "-parent", "1", "Book", "flights"},
+      {"-add", "-parent", "1", "Pack"},
+      {"-add", "-parent", "1.2", "Buy", "sunscreen"},
+      {"-complete", "1.1", "-rollup"},
+      {"-complete", "1.2", "-cascade", "-rollup"},
+    } {
+      cmd := exec.Command(cmdPath, args...)
+      cmd.Env = env
+      if out, err := cmd.CombinedOutput(); err != nil {
+        t.Fatalf("%v: %s", err, out)
+      }
+    }
+
+    cmd := exec.Command(cmdPath, "-list")
+    cmd.Env = env
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := "X 1: Plan trip (2/2)\n" +
+      "  X 1.1: Book flights\n" +
+      "  X 1.2: Pack (1/1)\n" +
+      "    X 1.2.1: Buy sunscreen\n"
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("ServeFlags", func(t *testing.T) {
+    serveFile := ".todo.serve.json"
+    defer os.Remove(serveFile)
+    defer os.Remove(serveFile + ".lock")
+
+    // Find a free port to serve on
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+      t.Fatal(err)
+    }
+    addr := ln.Addr().String()
+    ln.Close()
+
+    cmd := exec.Command(cmdPath, "serve", "-addr", addr)
+    cmd.Env = append(os.Environ(), "TODO_FILENAME="+serveFile)
+    if err := cmd.Start(); err != nil {
+      t.Fatal(err)
+    }
+    defer cmd.Wait()
+    defer cmd.Process.Kill()
+
+    // Wait for the server to listen on the address given after serve
+    var res *http.Response
+    for i := 0; i < 50; i++ {
+      res, err = http.Get("http://" + addr + "/todos")
+      if err == nil {
+        break
+      }
+      time.Sleep(100 * time.Millisecond)
+    }
+    if err != nil {
+      t.Fatal(err)
+    }
+    res.Body.Close()
+
+    if res.StatusCode != http.StatusOK {
+      t.Errorf("Expected status %d, got %d instead", http.StatusOK,
+        res.StatusCode)
+    }
+
+    out, err := exec.Command(cmdPath, "serve", "extra").CombinedOutput()
+    if err == nil {
+      t.Fatalf("Expected error for arguments after serve, got %q", out)
+    }
+
+    expected := "Unexpected arguments after serve: extra\n"
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+  })
+
+  t.Run("IDsNotReused", func(t *testing.T) {
+    idsFile := ".todo.ids.json"
+    defer os.Remove(idsFile)
+    defer os.Remove(idsFile + ".lock")
+    defer os.Remove(idsFile + ".journal")
+
+    env := append(os.Environ(), "TODO_FILENAME="+idsFile)
+
+    for _, args := range [][]string{
+      {"-add", "one"},
+      {"-add", "two"},
+      {"-del", "2"},
+      {"-del", "1"},
+      {"-add", "three"},
+    } {
+      cmd := exec.Command(cmdPath, args...)
+      cmd.Env = env
+      if out, err := cmd.CombinedOutput(); err != nil {
+        t.Fatalf("%v: %s", err, out)
+      }
+    }
+
+    cmd := exec.Command(cmdPath, "-list")
+    cmd.Env = env
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    expected := "  3: three\n"
+
+    if expected != string(out) {
+      t.Errorf("Expected %q, got %q instead\n", expected, string(out))
+    }
+
+    cmd = exec.Command(cmdPath, "-history")
+    cmd.Env = env
+    out, err = cmd.CombinedOutput()
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if !strings.Contains(string(out), "add: 3 three") {
+      t.Errorf("Expected history with item 3 added, got %q instead\n",
+        string(out))
+    }
+  })
 }
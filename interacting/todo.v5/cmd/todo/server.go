@@ -0,0 +1,399 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// server serves the list saved in a store as a JSON REST API:
+//
+//	GET    /todos                 list items, filtered by the project,
+//	                              context, tag and due parameters and
+//	                              sorted by the sort parameter
+//	POST   /todos                 add an item
+//	GET    /todos/{id}            get an item
+//	POST   /todos/{id}/complete   complete an item, with its subtasks and
+//	                              parents by the cascade and rollup
+//	                              parameters
+//	DELETE /todos/{id}            delete an item with its subtasks
+//
+// Items are addressed by ID or path, like 3.2. The list is locked and
+// read again on every request so the server and the command line can
+// change it at the same time
+type server struct {
+  filename string
+  store    todo.Store
+  journal  *todo.Journal
+}
+
+// newTask is the body of requests adding items. Parent is the ID or path
+// of the item to add a subtask to
+type newTask struct {
+  Task     string `json:"task"`
+  Parent   string `json:"parent"`
+  Priority string `json:"priority"`
+  Due      string `json:"due"`
+  Recur    string `json:"recur"`
+}
+
+// task is an item as replied by the API, with the same keys as newTask.
+// Due is a date like in requests. Dates and values that aren't set are
+// left out
+type task struct {
+  ID          int        `json:"id"`
+  Task        string     `json:"task"`
+  Done        bool       `json:"done"`
+  CreatedAt   time.Time  `json:"created_at"`
+  CompletedAt *time.Time `json:"completed_at,omitempty"`
+  Priority    string     `json:"priority,omitempty"`
+  Due         string     `json:"due,omitempty"`
+  Recur       string     `json:"recur,omitempty"`
+  Parent      int        `json:"parent,omitempty"`
+  Sub         int        `json:"sub,omitempty"`
+  Projects    []string   `json:"projects,omitempty"`
+  Contexts    []string   `json:"contexts,omitempty"`
+  Tags        []string   `json:"tags,omitempty"`
+}
+
+// newTaskReply returns the item at position i of l as replied by the API
+func newTaskReply(l todo.List, i int) task {
+  t := l[i]
+  r := task{
+    ID:        t.ID,
+    Task:      t.Task,
+    Done:      t.Done,
+    CreatedAt: t.CreatedAt,
+    Priority:  t.Priority,
+    Recur:     t.Recur,
+    Parent:    t.Parent,
+    Sub:       t.Sub,
+    Projects:  t.Projects,
+    Contexts:  t.Contexts,
+    Tags:      t.Tags,
+  }
+
+  if !t.CompletedAt.IsZero() {
+    r.CompletedAt = &t.CompletedAt
+  }
+  if !t.Due.IsZero() {
+    r.Due = t.Due.Format(todo.DateFormat)
+  }
+
+  return r
+}
+
+// badRequest is an error in the request, replied with 400 Bad Request
+type badRequest struct {
+  error
+}
+
+// newServer returns a server for the list saved in store, locked with
+// the file name of the list
+func newServer(filename string, store todo.Store) *server {
+  return &server{
+    filename: filename,
+    store:    store,
+    journal:  todo.NewJournal(filename),
+  }
+}
+
+// serve serves the list saved in store on addr until the server fails
+func serve(addr, filename string, store todo.Store) error {
+  s := &http.Server{
+    Addr:         addr,
+    Handler:      newServer(filename, store),
+    ReadTimeout:  10 * time.Second,
+    WriteTimeout: 10 * time.Second,
+  }
+
+  return s.ListenAndServe()
+}
+
+// ServeHTTP routes the requests to the handlers of the API
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  rest := strings.TrimPrefix(r.URL.Path, "/todos")
+  if rest == r.URL.Path || rest != "" && rest[0] != '/' {
+    replyError(w, http.StatusNotFound, fmt.Errorf("Not found"))
+    return
+  }
+
+  parts := strings.Split(strings.Trim(rest, "/"), "/")
+
+  switch {
+  case parts[0] == "" && r.Method == http.MethodGet:
+    s.list(w, r)
+  case parts[0] == "" && r.Method == http.MethodPost:
+    s.add(w, r)
+  case parts[0] == "":
+    methodNotAllowed(w, http.MethodGet, http.MethodPost)
+  case len(parts) == 1 && r.Method == http.MethodGet:
+    s.get(w, parts[0])
+  case len(parts) == 1 && r.Method == http.MethodDelete:
+    s.delete(w, parts[0])
+  case len(parts) == 1:
+    methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+  case len(parts) == 2 && parts[1] == "complete" &&
+    r.Method == http.MethodPost:
+    s.complete(w, r, parts[0])
+  case len(parts) == 2 && parts[1] == "complete":
+    methodNotAllowed(w, http.MethodPost)
+  default:
+    replyError(w, http.StatusNotFound, fmt.Errorf("Not found"))
+  }
+}
+
+// list replies with the items matching the filters of the request
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+  q := r.URL.Query()
+  tasks := []task{}
+
+  err := s.withList("", func(l *todo.List) error {
+    filtered := l.FilterBy(q.Get("project"), q.Get("context"), q.Get("tag"))
+
+    if due := q.Get("due"); due != "" {
+      var err error
+      filtered, err = filtered.FilterDue(due, time.Now())
+      if err != nil {
+        return badRequest{err}
+      }
+    }
+
+    if by := q.Get("sort"); by != "" {
+      if err := filtered.Sort(by); err != nil {
+        return badRequest{err}
+      }
+    }
+
+    for i := range filtered {
+      tasks = append(tasks, newTaskReply(filtered, i))
+    }
+    return nil
+  })
+  if err != nil {
+    fail(w, err)
+    return
+  }
+
+  replyJSON(w, http.StatusOK, tasks)
+}
+
+// add adds the item in the body of the request and replies with it
+func (s *server) add(w http.ResponseWriter, r *http.Request) {
+  t := newTask{}
+  if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+    fail(w, badRequest{fmt.Errorf("Invalid request body: %s", err)})
+    return
+  }
+
+  if strings.TrimSpace(t.Task) == "" {
+    fail(w, badRequest{fmt.Errorf("Task cannot be blank")})
+    return
+  }
+
+  var list *todo.List
+  i := 0
+
+  err := s.withList("add", func(l *todo.List) error {
+    if t.Parent != "" {
+      if _, err := resolve(l, t.Parent); err != nil {
+        return err
+      }
+    }
+
+    if err := addTask(l, t.Parent, t.Task); err != nil {
+      return err
+    }
+
+    // The new item is the last one
+    list, i = l, len(*l)-1
+    if err := setAttributes(l, (*l)[i].ID, t.Priority, t.Due,
+      t.Recur); err != nil {
+      return badRequest{err}
+    }
+
+    return nil
+  })
+  if err != nil {
+    fail(w, err)
+    return
+  }
+
+  // The item gets its final ID once the list is saved
+  added := newTaskReply(*list, i)
+
+  w.Header().Set("Location", fmt.Sprintf("/todos/%d", added.ID))
+
+  replyJSON(w, http.StatusCreated, added)
+}
+
+// get replies with the item at path
+func (s *server) get(w http.ResponseWriter, path string) {
+  var found task
+
+  err := s.withList("", func(l *todo.List) error {
+    i, err := resolve(l, path)
+    if err != nil {
+      return err
+    }
+
+    found = newTaskReply(*l, i)
+    return nil
+  })
+  if err != nil {
+    fail(w, err)
+    return
+  }
+
+  replyJSON(w, http.StatusOK, found)
+}
+
+// complete completes the item at path and replies with it
+func (s *server) complete(w http.ResponseWriter, r *http.Request,
+  path string) {
+
+  opts := todo.CompleteOptions{}
+  for name, opt := range map[string]*bool{"cascade": &opts.Cascade,
+    "rollup": &opts.RollUp} {
+    v := r.URL.Query().Get(name)
+    if v == "" {
+      continue
+    }
+
+    b, err := strconv.ParseBool(v)
+    if err != nil {
+      fail(w, badRequest{fmt.Errorf("Invalid %s %q: use true or false",
+        name, v)})
+      return
+    }
+    *opt = b
+  }
+
+  var completed task
+
+  err := s.withList("complete", func(l *todo.List) error {
+    i, err := resolve(l, path)
+    if err != nil {
+      return err
+    }
+
+    if err := l.CompleteWith((*l)[i].ID, opts); err != nil {
+      return err
+    }
+
+    // Completing only appends items so the item keeps its position
+    completed = newTaskReply(*l, i)
+    return nil
+  })
+  if err != nil {
+    fail(w, err)
+    return
+  }
+
+  replyJSON(w, http.StatusOK, completed)
+}
+
+// delete deletes the item at path with its subtasks
+func (s *server) delete(w http.ResponseWriter, path string) {
+  err := s.withList("delete", func(l *todo.List) error {
+    i, err := resolve(l, path)
+    if err != nil {
+      return err
+    }
+
+    return l.Delete((*l)[i].ID)
+  })
+  if err != nil {
+    fail(w, err)
+    return
+  }
+
+  w.WriteHeader(http.StatusNoContent)
+}
+
+// withList runs f on the list read from the store while holding the lock.
+// Unless op is empty the list is saved afterwards and the change recorded
+// in the journal as op
+func (s *server) withList(op string, f func(l *todo.List) error) error {
+  lock, err := todo.Lock(s.filename)
+  if err != nil {
+    return err
+  }
+  defer lock.Unlock()
+
+  l := &todo.List{}
+  if err := l.GetFrom(s.store); err != nil {
+    return err
+  }
+  before := append(todo.List{}, *l...)
+
+  if err := f(l); err != nil {
+    return err
+  }
+
+  if op == "" {
+    return nil
+  }
+
+  return save(s.store, s.journal, op, before, l)
+}
+
+// resolve returns the position in the list of the item at path. Paths
+// that aren't valid are bad requests
+func resolve(l *todo.List, path string) (int, error) {
+  id, err := l.Resolve(path)
+  if err != nil {
+    if todo.IsNotExist(err) {
+      return 0, err
+    }
+    return 0, badRequest{err}
+  }
+
+  for i, t := range *l {
+    if t.ID == id {
+      return i, nil
+    }
+  }
+
+  return 0, fmt.Errorf("Item %d does not exist", id)
+}
+
+// fail replies with the status matching err: 404 Not Found for items not
+// in the list, 400 Bad Request for errors in the request and 500 Internal
+// Server Error for any other error
+func fail(w http.ResponseWriter, err error) {
+  status := http.StatusInternalServerError
+
+  if _, ok := err.(badRequest); ok {
+    status = http.StatusBadRequest
+  } else if todo.IsNotExist(err) {
+    status = http.StatusNotFound
+  }
+
+  replyError(w, status, err)
+}
+
+// methodNotAllowed replies with 405 Method Not Allowed listing the
+// methods allowed
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+  w.Header().Set("Allow", strings.Join(allowed, ", "))
+  replyError(w, http.StatusMethodNotAllowed,
+    fmt.Errorf("Method not allowed: use %s", strings.Join(allowed, " or ")))
+}
+
+// replyError replies with the status and the message of err as JSON
+func replyError(w http.ResponseWriter, status int, err error) {
+  replyJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// replyJSON replies with the status and v encoded as JSON
+func replyJSON(w http.ResponseWriter, status int, v interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(v)
+}
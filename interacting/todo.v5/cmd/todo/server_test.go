@@ -0,0 +1,258 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// newTestServer starts a server for a new list in a temporary directory.
+// The returned function stops it and removes the directory
+func newTestServer(t *testing.T) (*httptest.Server, string, func()) {
+  t.Helper()
+
+  dir, err := ioutil.TempDir("", "todoserver")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  fname := filepath.Join(dir, "todo.json")
+  store, err := todo.NewStore(fname, "")
+  if err != nil {
+    os.RemoveAll(dir)
+    t.Fatal(err)
+  }
+
+  ts := httptest.NewServer(newServer(fname, store))
+
+  return ts, fname, func() {
+    ts.Close()
+    os.RemoveAll(dir)
+  }
+}
+
+// request sends a request to the server and returns the status and body
+// of the response
+func request(t *testing.T, ts *httptest.Server, method, path,
+  body string) (int, string) {
+
+  t.Helper()
+
+  req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  res, err := ts.Client().Do(req)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer res.Body.Close()
+
+  out, err := ioutil.ReadAll(res.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return res.StatusCode, string(out)
+}
+
+// TestServer tests the endpoints of the API in order, each step using
+// the list left by the previous ones
+func TestServer(t *testing.T) {
+  ts, fname, cleanup := newTestServer(t)
+  defer cleanup()
+
+  testCases := []struct {
+    name      string
+    method    string
+    path      string
+    body      string
+    expStatus int
+    // Strings the body must contain
+    exp []string
+  }{
+    {name: "ListEmpty", method: http.MethodGet, path: "/todos",
+      expStatus: http.StatusOK, exp: []string{"[]"}},
+    {name: "Add", method: http.MethodPost, path: "/todos",
+      body:      `{"task": "Plan trip +home", "priority": "A", "due": "2100-01-04"}`,
+      expStatus: http.StatusCreated,
+      exp: []string{`"id":1`, `"task":"Plan trip +home"`, `"priority":"A"`,
+        `"due":"2100-01-04"`, `"projects":["home"]`}},
+    {name: "AddSubtask", method: http.MethodPost, path: "/todos",
+      body:      `{"task": "Book flights", "parent": "1"}`,
+      expStatus: http.StatusCreated, exp: []string{`"id":2`, `"parent":1`,
+        `"sub":1`}},
+    {name: "AddOther", method: http.MethodPost, path: "/todos",
+      body:      `{"task": "Call the bank @phone", "recur": "every:7"}`,
+      expStatus: http.StatusCreated, exp: []string{`"id":3`,
+        `"recur":"every:7"`}},
+    {name: "AddBlank", method: http.MethodPost, path: "/todos",
+      body: `{"task": " "}`, expStatus: http.StatusBadRequest,
+      exp: []string{`"error":"Task cannot be blank"`}},
+    {name: "AddInvalidBody", method: http.MethodPost, path: "/todos",
+      body: `{"task": `, expStatus: http.StatusBadRequest,
+      exp: []string{"Invalid request body"}},
+    {name: "AddInvalidPriority", method: http.MethodPost, path: "/todos",
+      body: `{"task": "Task", "priority": "Z9"}`,
+      expStatus: http.StatusBadRequest, exp: []string{"Invalid priority"}},
+    {name: "AddMissingParent", method: http.MethodPost, path: "/todos",
+      body: `{"task": "Task", "parent": "9"}`,
+      expStatus: http.StatusNotFound, exp: []string{"Item 9 does not exist"}},
+    {name: "ListProject", method: http.MethodGet, path: "/todos?project=home",
+      expStatus: http.StatusOK, exp: []string{`"id":1`}},
+    {name: "ListContext", method: http.MethodGet,
+      path: "/todos?context=phone&sort=priority", expStatus: http.StatusOK,
+      exp: []string{`"id":3`}},
+    {name: "ListInvalidDue", method: http.MethodGet, path: "/todos?due=year",
+      expStatus: http.StatusBadRequest, exp: []string{"Invalid due filter"}},
+    {name: "Get", method: http.MethodGet, path: "/todos/1",
+      expStatus: http.StatusOK, exp: []string{`"task":"Plan trip +home"`}},
+    {name: "GetPath", method: http.MethodGet, path: "/todos/1.1",
+      expStatus: http.StatusOK, exp: []string{`"task":"Book flights"`}},
+    {name: "GetMissing", method: http.MethodGet, path: "/todos/9",
+      expStatus: http.StatusNotFound,
+      exp: []string{`"error":"Item 9 does not exist"`}},
+    {name: "GetInvalid", method: http.MethodGet, path: "/todos/one",
+      expStatus: http.StatusBadRequest, exp: []string{"Invalid item"}},
+    {name: "CompleteRollUp", method: http.MethodPost,
+      path: "/todos/1.1/complete?rollup=true", expStatus: http.StatusOK,
+      exp: []string{`"id":2`, `"done":true`, `"completed_at":`}},
+    {name: "CompletedParent", method: http.MethodGet, path: "/todos/1",
+      expStatus: http.StatusOK, exp: []string{`"done":true`}},
+    {name: "CompleteRecurring", method: http.MethodPost,
+      path: "/todos/3/complete", expStatus: http.StatusOK,
+      exp: []string{`"id":3`, `"done":true`}},
+    {name: "NextOccurrence", method: http.MethodGet, path: "/todos/4",
+      expStatus: http.StatusOK, exp: []string{`"done":false`,
+        `"recur":"every:7"`}},
+    {name: "CompleteInvalidOption", method: http.MethodPost,
+      path: "/todos/4/complete?cascade=maybe",
+      expStatus: http.StatusBadRequest, exp: []string{"Invalid cascade"}},
+    {name: "CompleteMissing", method: http.MethodPost,
+      path: "/todos/9/complete", expStatus: http.StatusNotFound,
+      exp: []string{"Item 9 does not exist"}},
+    {name: "CompleteGet", method: http.MethodGet, path: "/todos/4/complete",
+      expStatus: http.StatusMethodNotAllowed,
+      exp: []string{"Method not allowed"}},
+    {name: "Delete", method: http.MethodDelete, path: "/todos/1",
+      expStatus: http.StatusNoContent},
+    {name: "DeletedSubtask", method: http.MethodGet, path: "/todos/2",
+      expStatus: http.StatusNotFound},
+    {name: "DeleteMissing", method: http.MethodDelete, path: "/todos/1",
+      expStatus: http.StatusNotFound, exp: []string{"Item 1 does not exist"}},
+    {name: "PutList", method: http.MethodPut, path: "/todos",
+      expStatus: http.StatusMethodNotAllowed},
+    {name: "UnknownPath", method: http.MethodGet, path: "/items",
+      expStatus: http.StatusNotFound},
+    {name: "UnknownAction", method: http.MethodPost, path: "/todos/3/undo",
+      expStatus: http.StatusNotFound},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      status, body := request(t, ts, tc.method, tc.path, tc.body)
+
+      if status != tc.expStatus {
+        t.Errorf("Expected status %d, got %d instead: %s", tc.expStatus,
+          status, body)
+      }
+
+      for _, exp := range tc.exp {
+        if !strings.Contains(body, exp) {
+          t.Errorf("Expected body with %q, got %q instead", exp, body)
+        }
+      }
+    })
+  }
+
+  // The server saves the list in the same file as the command line,
+  // recording every change in the journal
+  l := todo.List{}
+  if err := l.Get(fname); err != nil {
+    t.Fatal(err)
+  }
+
+  expected := "X 3: Call the bank @phone (repeats every 7 days after completion)\n"
+  if !strings.HasPrefix(l.String(), expected) || len(l) != 2 {
+    t.Errorf("Expected list starting with %q, got %q instead", expected,
+      l.String())
+  }
+
+  entries, err := todo.NewJournal(fname).History(historySize)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  ops := []string{}
+  for _, e := range entries {
+    ops = append(ops, e.Op)
+  }
+
+  expOps := "add add add complete complete delete"
+  if strings.Join(ops, " ") != expOps {
+    t.Errorf("Expected operations %q, got %q instead", expOps, ops)
+  }
+}
+
+// TestServerConcurrentAdds tests that concurrent requests don't lose
+// each other's changes
+func TestServerConcurrentAdds(t *testing.T) {
+  ts, _, cleanup := newTestServer(t)
+  defer cleanup()
+
+  adds := 20
+  errCh := make(chan error, adds)
+
+  for i := 0; i < adds; i++ {
+    go func(i int) {
+      body := fmt.Sprintf(`{"task": "concurrent %d"}`, i)
+      res, err := ts.Client().Post(ts.URL+"/todos", "application/json",
+        strings.NewReader(body))
+      if err != nil {
+        errCh <- err
+        return
+      }
+      res.Body.Close()
+
+      if res.StatusCode != http.StatusCreated {
+        err = fmt.Errorf("Unexpected status %d", res.StatusCode)
+      }
+      errCh <- err
+    }(i)
+  }
+
+  for i := 0; i < adds; i++ {
+    if err := <-errCh; err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  status, body := request(t, ts, http.MethodGet, "/todos", "")
+  if status != http.StatusOK {
+    t.Fatalf("Expected status %d, got %d instead", http.StatusOK, status)
+  }
+
+  tasks := []task{}
+  if err := json.Unmarshal([]byte(body), &tasks); err != nil {
+    t.Fatal(err)
+  }
+
+  ids := map[int]bool{}
+  for _, task := range tasks {
+    ids[task.ID] = true
+  }
+
+  if len(tasks) != adds || len(ids) != adds {
+    t.Errorf("Expected %d items with distinct IDs, got %v instead", adds,
+      tasks)
+  }
+}
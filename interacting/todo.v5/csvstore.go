@@ -0,0 +1,162 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns of the CSV format
+var csvHeader = []string{"id", "task", "done", "priority", "due",
+	"created_at", "completed_at", "recur", "parent", "sub"}
+
+// csvStore saves the List as CSV with a header, one item per record.
+// Projects, contexts and tags are parsed again from the task. Files
+// saved before items had a recurrence rule or subtasks lack the last
+// columns. The highest ID given comes before the header, like in the
+// todo.txt format, when the newest items were deleted
+type csvStore struct {
+	filename string
+}
+
+func (s *csvStore) Load() (List, error) {
+	l, _, err := s.read()
+	return l, err
+}
+
+func (s *csvStore) Save(l List) error {
+	last, err := s.lastID()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(formatLastID(l, last))
+
+	w := csv.NewWriter(&buf)
+	w.Write(csvHeader)
+
+	for _, t := range l {
+		w.Write([]string{strconv.Itoa(t.ID), t.Task,
+			strconv.FormatBool(t.Done), t.Priority, formatTime(t.Due, DateFormat),
+			formatTime(t.CreatedAt, time.RFC3339Nano),
+			formatTime(t.CompletedAt, time.RFC3339Nano), t.Recur,
+			strconv.Itoa(t.Parent), strconv.Itoa(t.Sub)})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return writeFile(s.filename, buf.Bytes())
+}
+
+func (s *csvStore) lastID() (int, error) {
+	l, last, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+
+	if max := l.maxID(); max > last {
+		last = max
+	}
+
+	return last, nil
+}
+
+// read returns the items in the file and the highest ID given saved with
+// them
+func (s *csvStore) read() (List, int, error) {
+	l := List{}
+
+	file, err := readFile(s.filename)
+	if err != nil || len(file) == 0 {
+		return l, 0, err
+	}
+
+	last, file := parseLastID(file)
+
+	records, err := csv.NewReader(bytes.NewReader(file)).ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Files with blank lines only have no header
+	if len(records) == 0 {
+		return l, last, nil
+	}
+
+	for _, rec := range records[1:] {
+		t, err := parseCSV(rec)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid CSV record %q: %s", rec, err)
+		}
+
+		l = append(l, t)
+	}
+
+	return l, last, nil
+}
+
+// parseCSV returns the item saved in the CSV record rec
+func parseCSV(rec []string) (item, error) {
+	if len(rec) < 7 {
+		return item{}, fmt.Errorf("Expected at least 7 columns, got %d",
+			len(rec))
+	}
+
+	t := item{Task: rec[1], Priority: rec[3]}
+	var err error
+
+	if t.ID, err = strconv.Atoi(rec[0]); err != nil {
+		return item{}, err
+	}
+	if t.Done, err = strconv.ParseBool(rec[2]); err != nil {
+		return item{}, err
+	}
+	if t.Due, err = parseTime(rec[4], DateFormat); err != nil {
+		return item{}, err
+	}
+	if t.CreatedAt, err = parseTime(rec[5], time.RFC3339Nano); err != nil {
+		return item{}, err
+	}
+	if t.CompletedAt, err = parseTime(rec[6], time.RFC3339Nano); err != nil {
+		return item{}, err
+	}
+	if len(rec) > 7 {
+		t.Recur = rec[7]
+	}
+	if len(rec) > 9 {
+		if t.Parent, err = strconv.Atoi(rec[8]); err != nil {
+			return item{}, err
+		}
+		if t.Sub, err = strconv.Atoi(rec[9]); err != nil {
+			return item{}, err
+		}
+	}
+
+	return t, nil
+}
+
+// formatTime formats t with layout, or returns an empty string for the
+// zero time
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(layout)
+}
+
+// parseTime parses s with layout in the local time zone. An empty string
+// is the zero time
+func parseTime(s, layout string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.ParseInLocation(layout, s, time.Local)
+}
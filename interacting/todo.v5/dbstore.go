@@ -0,0 +1,298 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// dbMagic starts every database file and identifies its format
+const dbMagic = "TODODB1\n"
+
+// Operations of the database records
+const (
+	opPut    byte = 'P'
+	opDelete byte = 'D'
+)
+
+// dbCompactMin is the size of the smallest database worth compacting
+const dbCompactMin = 64 * 1024
+
+// dbStore keeps the items in a single file key-value database, using
+// their IDs as keys. The file is a log of records that put or delete an
+// item, so saving the List only appends the items added, changed or
+// deleted since it was loaded, no matter how many items it has. Every
+// record carries a checksum and loading stops at the first damaged one,
+// left by a write that didn't finish. The file is compacted to the last
+// version of every item when old versions take most of it. The highest ID
+// given is put with the ID 0, which no item has, when the newest items
+// were deleted
+type dbStore struct {
+	filename string
+	// encoded items in the file by ID, the size of the records that
+	// hold them and the size of the valid part of the file
+	items map[int][]byte
+	sizes map[int]int
+	size  int
+	// highest ID given saved in the file, 0 when it isn't
+	last int
+}
+
+// dbLastID is the ID of the record with the highest ID given
+const dbLastID = 0
+
+func (s *dbStore) Load() (List, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	l := List{}
+	for _, id := range s.ids() {
+		t := item{}
+		if err := json.Unmarshal(s.items[id], &t); err != nil {
+			return nil, err
+		}
+
+		l = append(l, t)
+	}
+
+	return l, nil
+}
+
+func (s *dbStore) Save(l List) error {
+	// The file may have changed since the List was loaded
+	if err := s.load(); err != nil {
+		return err
+	}
+	last := s.highestID()
+
+	var recs bytes.Buffer
+	live := map[int]bool{}
+
+	for _, t := range l {
+		if t.ID == 0 {
+			return fmt.Errorf("Item %q has no ID", t.Task)
+		}
+		live[t.ID] = true
+
+		value, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+
+		if old, ok := s.items[t.ID]; ok && bytes.Equal(old, value) {
+			continue
+		}
+
+		rec := encodeRecord(opPut, t.ID, value)
+		recs.Write(rec)
+		s.items[t.ID], s.sizes[t.ID] = value, len(rec)
+	}
+
+	for _, id := range s.ids() {
+		if !live[id] {
+			recs.Write(encodeRecord(opDelete, id, nil))
+			delete(s.items, id)
+			delete(s.sizes, id)
+		}
+	}
+
+	if last > l.maxID() && last != s.last {
+		rec := encodeRecord(opPut, dbLastID, []byte(strconv.Itoa(last)))
+		recs.Write(rec)
+		s.last, s.sizes[dbLastID] = last, len(rec)
+	}
+
+	liveSize := len(dbMagic)
+	for _, n := range s.sizes {
+		liveSize += n
+	}
+
+	if size := s.size + recs.Len(); size > dbCompactMin && size > 2*liveSize {
+		return s.compact()
+	}
+
+	return s.append(recs.Bytes())
+}
+
+func (s *dbStore) lastID() (int, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+
+	return s.highestID(), nil
+}
+
+// highestID returns the highest ID given in the database loaded
+func (s *dbStore) highestID() int {
+	last := s.last
+	for id := range s.items {
+		if id > last {
+			last = id
+		}
+	}
+
+	return last
+}
+
+// load reads every valid record of the file. A missing file is an empty
+// database
+func (s *dbStore) load() error {
+	s.items, s.sizes, s.size, s.last = map[int][]byte{}, map[int]int{}, 0, 0
+
+	file, err := readFile(s.filename)
+	if err != nil || len(file) == 0 {
+		return err
+	}
+
+	if !bytes.HasPrefix(file, []byte(dbMagic)) {
+		return fmt.Errorf("Not a todo database: %s", s.filename)
+	}
+	s.size = len(dbMagic)
+
+	for rest := file[s.size:]; ; {
+		op, id, value, n := decodeRecord(rest)
+		if n == 0 {
+			// A damaged record is overwritten by the next save
+			return nil
+		}
+
+		switch {
+		case id == dbLastID:
+			s.last, _ = strconv.Atoi(string(value))
+			s.sizes[id] = n
+		case op == opPut:
+			s.items[id], s.sizes[id] = value, n
+		case op == opDelete:
+			delete(s.items, id)
+			delete(s.sizes, id)
+		}
+
+		s.size += n
+		rest = rest[n:]
+	}
+}
+
+// ids returns the IDs of the items in the database in ascending order
+func (s *dbStore) ids() []int {
+	ids := make([]int, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+// append writes recs after the last valid record of the file, creating
+// it when needed
+func (s *dbStore) append(recs []byte) error {
+	if s.size == 0 {
+		recs = append([]byte(dbMagic), recs...)
+	}
+
+	f, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteAt(recs, int64(s.size)); err != nil {
+		f.Close()
+		return err
+	}
+	s.size += len(recs)
+
+	// Remove what's left of a damaged record
+	if err := f.Truncate(int64(s.size)); err != nil {
+		f.Close()
+		return err
+	}
+
+	// A crash before the records reach the disk leaves at most a damaged
+	// record at the end
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// compact replaces the file with one holding only the last version of
+// every item. The file is replaced atomically so the database is never
+// left half written
+func (s *dbStore) compact() error {
+	var buf bytes.Buffer
+	buf.WriteString(dbMagic)
+
+	if s.last > 0 {
+		rec := encodeRecord(opPut, dbLastID, []byte(strconv.Itoa(s.last)))
+		buf.Write(rec)
+		s.sizes[dbLastID] = len(rec)
+	}
+
+	for _, id := range s.ids() {
+		rec := encodeRecord(opPut, id, s.items[id])
+		buf.Write(rec)
+		s.sizes[id] = len(rec)
+	}
+
+	if err := writeFile(s.filename, buf.Bytes()); err != nil {
+		return err
+	}
+	s.size = buf.Len()
+
+	return nil
+}
+
+// encodeRecord returns a record with the operation op on the item id.
+// The value is the encoded item, empty to delete it:
+//
+//	op | uvarint id | uvarint length | value | CRC-32 of the rest
+func encodeRecord(op byte, id int, value []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+
+	rec := []byte{op}
+	rec = append(rec, n[:binary.PutUvarint(n[:], uint64(id))]...)
+	rec = append(rec, n[:binary.PutUvarint(n[:], uint64(len(value)))]...)
+	rec = append(rec, value...)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(rec))
+
+	return append(rec, sum[:]...)
+}
+
+// decodeRecord returns the record at the start of b and its size. The
+// size is 0 when b doesn't start with a complete and valid record
+func decodeRecord(b []byte) (byte, int, []byte, int) {
+	if len(b) == 0 || (b[0] != opPut && b[0] != opDelete) {
+		return 0, 0, nil, 0
+	}
+
+	i := 1
+	id, n := binary.Uvarint(b[i:])
+	if n <= 0 {
+		return 0, 0, nil, 0
+	}
+	i += n
+
+	length, n := binary.Uvarint(b[i:])
+	if n <= 0 || length > uint64(len(b)) {
+		return 0, 0, nil, 0
+	}
+	i += n
+
+	end := i + int(length)
+	if end+4 > len(b) ||
+		crc32.ChecksumIEEE(b[:end]) != binary.BigEndian.Uint32(b[end:]) {
+		return 0, 0, nil, 0
+	}
+
+	return b[0], int(id), b[i:end], end + 4
+}
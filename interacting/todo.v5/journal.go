@@ -0,0 +1,298 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Change is the state of an item before and after an operation. Before is
+// nil for added items and After is nil for deleted ones
+type Change struct {
+	ID     int
+	Before *item `json:",omitempty"`
+	After  *item `json:",omitempty"`
+}
+
+// Entry is an operation recorded in the journal with every item it
+// changed. Undo and redo entries name the entry they undid or redid as
+// their Target
+type Entry struct {
+	Seq     int
+	Time    time.Time
+	Op      string
+	Target  int `json:",omitempty"`
+	Changes []Change
+}
+
+// String prints the entry as a line of history
+func (e Entry) String() string {
+	op := e.Op
+	if e.Target > 0 {
+		op = fmt.Sprintf("%s #%d", op, e.Target)
+	}
+
+	items := []string{}
+	for _, c := range e.Changes {
+		t := c.After
+		if t == nil {
+			t = c.Before
+		}
+		items = append(items, fmt.Sprintf("%d %s", c.ID, t.Task))
+	}
+
+	return fmt.Sprintf("%s #%d %s: %s", e.Time.Format("2006-01-02 15:04:05"),
+		e.Seq, op, strings.Join(items, "; "))
+}
+
+// Journal records the operations on a List in an append-only file next to
+// the file of the List, so they can be undone and redone
+type Journal struct {
+	filename string
+}
+
+// NewJournal returns the Journal of the List saved in filename
+func NewJournal(filename string) *Journal {
+	return &Journal{filename: filename + ".journal"}
+}
+
+// Record appends the operation op that turned the List before into after.
+// Nothing is recorded when no item changed
+func (j *Journal) Record(op string, before, after List) error {
+	changes := diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	entries, err := j.entries()
+	if err != nil {
+		return err
+	}
+
+	return j.Append(Entry{Seq: nextSeq(entries), Time: time.Now(), Op: op,
+		Changes: changes})
+}
+
+// Append writes the entry e at the end of the journal
+func (j *Journal) Append(e Entry) error {
+	js, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.filename, os.O_APPEND|os.O_CREATE|os.O_RDWR,
+		0644)
+	if err != nil {
+		return err
+	}
+
+	// A write that didn't finish leaves a line without its new line. The
+	// entry starts on its own line anyway
+	line := append(js, '\n')
+	last := make([]byte, 1)
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil &&
+			last[0] != '\n' {
+			line = append([]byte("\n"), line...)
+		}
+	}
+
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// History returns the last n entries of the journal, oldest first
+func (j *Journal) History(n int) ([]Entry, error) {
+	entries, err := j.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	return entries, nil
+}
+
+// Undo reverts the last operation not undone yet in l. It returns the
+// entry to append to the journal once l is saved
+func (j *Journal) Undo(l *List) (Entry, error) {
+	return j.replay(l, "undo")
+}
+
+// Redo applies again the last operation undone in l. It returns the entry
+// to append to the journal once l is saved
+func (j *Journal) Redo(l *List) (Entry, error) {
+	return j.replay(l, "redo")
+}
+
+// replay undoes or redoes an operation. The operations that can be undone
+// and redone are two stacks built from the journal: undoing moves the
+// last operation to the redo stack and redoing moves it back. Any other
+// operation empties the redo stack
+func (j *Journal) replay(l *List, op string) (Entry, error) {
+	entries, err := j.entries()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	bySeq := map[int]Entry{}
+	done, undone := []int{}, []int{}
+
+	for _, e := range entries {
+		bySeq[e.Seq] = e
+
+		switch {
+		case e.Op == "undo" && len(done) > 0:
+			done = done[:len(done)-1]
+			undone = append(undone, e.Target)
+		case e.Op == "redo" && len(undone) > 0:
+			undone = undone[:len(undone)-1]
+			done = append(done, e.Target)
+		case e.Op == "undo" || e.Op == "redo":
+			// Entries lost to damaged lines leave nothing to move
+		default:
+			done = append(done, e.Seq)
+			undone = undone[:0]
+		}
+	}
+
+	stack := done
+	if op == "redo" {
+		stack = undone
+	}
+
+	if len(stack) == 0 {
+		return Entry{}, fmt.Errorf("Nothing to %s", op)
+	}
+
+	target := bySeq[stack[len(stack)-1]]
+	e := Entry{Seq: nextSeq(entries), Time: time.Now(), Op: op,
+		Target: target.Seq}
+
+	for i := range target.Changes {
+		c := target.Changes[i]
+
+		// Undoing goes back from the last change of the operation
+		if op == "undo" {
+			c = target.Changes[len(target.Changes)-1-i]
+			c.Before, c.After = c.After, c.Before
+		}
+
+		l.put(c.ID, c.After)
+		e.Changes = append(e.Changes, c)
+	}
+
+	return e, nil
+}
+
+// entries reads every entry of the journal. Damaged lines, left by
+// writes that didn't finish, are skipped
+func (j *Journal) entries() ([]Entry, error) {
+	file, err := readFile(j.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, line := range bytes.Split(file, []byte("\n")) {
+		e := Entry{}
+		if err := json.Unmarshal(line, &e); err == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// nextSeq returns the number of the entry following entries
+func nextSeq(entries []Entry) int {
+	if len(entries) == 0 {
+		return 1
+	}
+
+	return entries[len(entries)-1].Seq + 1
+}
+
+// diff returns the changes that turn the List before into after
+func diff(before, after List) []Change {
+	changes := []Change{}
+	old := map[int]item{}
+
+	for _, t := range before {
+		old[t.ID] = t
+	}
+
+	for i := range after {
+		t := after[i]
+
+		b, ok := old[t.ID]
+		delete(old, t.ID)
+
+		switch {
+		case !ok:
+			changes = append(changes, Change{ID: t.ID, After: &t})
+		case !equal(b, t):
+			changes = append(changes, Change{ID: t.ID, Before: &b, After: &t})
+		}
+	}
+
+	// Deleted items keep the order they had
+	for i := range before {
+		if t, ok := old[before[i].ID]; ok {
+			changes = append(changes, Change{ID: t.ID, Before: &t})
+		}
+	}
+
+	return changes
+}
+
+// equal reports whether a and b hold the same item
+func equal(a, b item) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+
+	return bytes.Equal(ja, jb)
+}
+
+// put replaces the item with the given ID by t, deleting it when t is
+// nil. Items missing from the List are inserted before the first item with
+// a higher ID
+func (l *List) put(id int, t *item) {
+	ls := *l
+
+	for i := range ls {
+		if ls[i].ID == id {
+			if t == nil {
+				*l = append(ls[:i], ls[i+1:]...)
+			} else {
+				ls[i] = *t
+			}
+			return
+		}
+	}
+
+	if t == nil {
+		return
+	}
+
+	i := 0
+	for i < len(ls) && ls[i].ID < id {
+		i++
+	}
+
+	*l = append(ls[:i], append(List{*t}, ls[i:]...)...)
+}
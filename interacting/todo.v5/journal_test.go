@@ -0,0 +1,179 @@
+package todo_test
+
+import (
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// apply runs the operation f on l and records it in the journal
+func apply(t *testing.T, j *todo.Journal, l *todo.List, op string,
+  f func()) {
+
+  t.Helper()
+
+  before := append(todo.List{}, *l...)
+  f()
+
+  if err := j.Record(op, before, *l); err != nil {
+    t.Fatal(err)
+  }
+}
+
+// replay undoes or redoes the last operation and records it
+func replay(t *testing.T, j *todo.Journal, l *todo.List, undo bool) {
+  t.Helper()
+
+  f := j.Redo
+  if undo {
+    f = j.Undo
+  }
+
+  e, err := f(l)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if err := j.Append(e); err != nil {
+    t.Fatal(err)
+  }
+}
+
+// TestJournalUndoRedo tests undoing and redoing operations
+func TestJournalUndoRedo(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  j := todo.NewJournal(filepath.Join(dir, "todo.json"))
+  l := todo.List{}
+
+  apply(t, j, &l, "add", func() { l.Add("Task 1") })
+  apply(t, j, &l, "add", func() { l.Add("Task 2") })
+  apply(t, j, &l, "complete", func() { l.Complete(1) })
+
+  // Times read back from the journal have no monotonic clock reading so
+  // lists are compared by how they print
+  done := append(todo.List{}, l...)
+
+  apply(t, j, &l, "delete", func() { l.Delete(1) })
+
+  // Undoing the delete brings back the completed item in its place
+  replay(t, j, &l, true)
+  if done.String() != l.String() {
+    t.Errorf("Expected %q, got %q instead.", done.String(), l.String())
+  }
+
+  replay(t, j, &l, true)
+  if l[0].Done {
+    t.Errorf("Task 1 should not be completed.")
+  }
+
+  // Redoing applies the operations again in order
+  replay(t, j, &l, false)
+  if done.String() != l.String() {
+    t.Errorf("Expected %q, got %q instead.", done.String(), l.String())
+  }
+
+  replay(t, j, &l, false)
+  if len(l) != 1 || l[0].ID != 2 {
+    t.Errorf("Expected only item 2, got %v instead.", l)
+  }
+
+  if _, err := j.Redo(&l); err == nil ||
+    !strings.Contains(err.Error(), "Nothing to redo") {
+    t.Errorf("Expected error Nothing to redo, got %v instead.", err)
+  }
+
+  // Undoing every operation empties the list
+  for i := 0; i < 4; i++ {
+    replay(t, j, &l, true)
+  }
+  if len(l) != 0 {
+    t.Errorf("Expected empty list, got %v instead.", l)
+  }
+
+  if _, err := j.Undo(&l); err == nil ||
+    !strings.Contains(err.Error(), "Nothing to undo") {
+    t.Errorf("Expected error Nothing to undo, got %v instead.", err)
+  }
+}
+
+// TestJournalNewOpClearsRedo tests that a new operation can't be followed
+// by redoing operations undone before it
+func TestJournalNewOpClearsRedo(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  j := todo.NewJournal(filepath.Join(dir, "todo.json"))
+  l := todo.List{}
+
+  apply(t, j, &l, "add", func() { l.Add("Task 1") })
+  replay(t, j, &l, true)
+  apply(t, j, &l, "add", func() { l.Add("Task 2") })
+
+  if _, err := j.Redo(&l); err == nil {
+    t.Errorf("Expected error. Got nil instead.")
+  }
+
+  // Operations that change nothing are not recorded
+  apply(t, j, &l, "complete", func() {})
+
+  entries, err := j.History(10)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  ops := []string{}
+  for _, e := range entries {
+    ops = append(ops, e.Op)
+  }
+
+  exp := []string{"add", "undo", "add"}
+  if !reflect.DeepEqual(exp, ops) {
+    t.Errorf("Expected %v, got %v instead.", exp, ops)
+  }
+}
+
+// TestJournalHistory tests listing the last entries and skipping damaged
+// lines
+func TestJournalHistory(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  fname := filepath.Join(dir, "todo.json")
+  j := todo.NewJournal(fname)
+  l := todo.List{}
+
+  apply(t, j, &l, "add", func() { l.Add("Task 1") })
+
+  // Simulate a write that didn't finish
+  f, err := os.OpenFile(fname+".journal", os.O_APPEND|os.O_WRONLY, 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  f.WriteString(`{"Seq":2,"Op":"ad`)
+  f.Close()
+
+  apply(t, j, &l, "add", func() { l.Add("Task 2") })
+  apply(t, j, &l, "add", func() { l.Add("Task 3") })
+
+  entries, err := j.History(2)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(entries) != 2 {
+    t.Fatalf("Expected 2 entries, got %d instead.", len(entries))
+  }
+
+  for i, exp := range []string{"#2 add: 2 Task 2", "#3 add: 3 Task 3"} {
+    if !strings.HasSuffix(entries[i].String(), exp) {
+      t.Errorf("Expected entry ending with %q, got %q instead.", exp,
+        entries[i].String())
+    }
+  }
+}
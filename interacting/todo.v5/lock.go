@@ -0,0 +1,17 @@
+package todo
+
+import "os"
+
+// FileLock is an advisory lock held on a file while its List is read,
+// changed and saved, so concurrent processes don't lose each other's
+// changes
+type FileLock struct {
+	f *os.File
+}
+
+// Lock waits until no other process holds the lock of filename and takes
+// it. The lock is held on a separate file named after filename since
+// saving replaces filename with a new file
+func Lock(filename string) (*FileLock, error) {
+	return lockFile(filename + ".lock")
+}
@@ -0,0 +1,46 @@
+//go:build !windows && !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !aix && !solaris
+// +build !windows,!linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!aix,!solaris
+
+package todo
+
+import (
+	"os"
+	"time"
+)
+
+// staleLock is the age of the lock files taken to be left by processes
+// that ended without removing them. Locks are held for much less
+const staleLock = time.Minute
+
+// lockFile takes the lock by creating the file name, on systems without
+// file locks, waiting while another process holds it. Since the file
+// outlives processes that crash, it's removed once it's stale
+func lockFile(name string) (*FileLock, error) {
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return &FileLock{f: f}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(name); err == nil &&
+			time.Since(info.ModTime()) > staleLock {
+			os.Remove(name)
+			continue
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Unlock releases the lock by removing its file
+func (l *FileLock) Unlock() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(l.f.Name())
+}
@@ -0,0 +1,41 @@
+//go:build aix || solaris
+// +build aix solaris
+
+package todo
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive fcntl lock on the file name, creating it
+// when needed, on systems without flock
+func lockFile(name string) (*FileLock, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// A zero length locks the whole file
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK}
+	for {
+		err = syscall.FcntlFlock(f.Fd(), syscall.F_SETLKW, &lk)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place since removing
+// it would let another process lock a different file with the same name
+func (l *FileLock) Unlock() error {
+	// Closing the file releases the lock
+	return l.f.Close()
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package todo
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on the file name, creating it when
+// needed
+func lockFile(name string) (*FileLock, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place since removing
+// it would let another process lock a different file with the same name
+func (l *FileLock) Unlock() error {
+	// Closing the file releases the lock
+	return l.f.Close()
+}
@@ -0,0 +1,53 @@
+package todo
+
+import (
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Procedures locking ranges of files. Windows releases the locks of a
+// process when it ends, even when it crashes
+var (
+	kernel32       = syscall.NewLazyDLL("kernel32.dll")
+	procLockFile   = kernel32.NewProc("LockFileEx")
+	procUnlockFile = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockfileExclusiveLock asks LockFileEx for an exclusive lock
+const lockfileExclusiveLock = 0x2
+
+// lockFile takes an exclusive lock on the file name with LockFileEx,
+// creating it when needed and waiting while another process holds it
+func lockFile(name string) (*FileLock, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the whole file
+	ol := new(syscall.Overlapped)
+	r, _, err := procLockFile.Call(f.Fd(), lockfileExclusiveLock, 0,
+		math.MaxUint32, math.MaxUint32, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place since removing
+// it would let another process lock a different file with the same name
+func (l *FileLock) Unlock() error {
+	ol := new(syscall.Overlapped)
+	r, _, err := procUnlockFile.Call(l.f.Fd(), 0, math.MaxUint32,
+		math.MaxUint32, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,280 @@
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of recurrence rules
+const (
+	recurDaily   = "daily"
+	recurWeekly  = "weekly"
+	recurMonthly = "monthly"
+	recurEvery   = "every"
+)
+
+// weekdayNames are the names of the days in weekly rules
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// rule is a parsed recurrence rule. Rules are saved in items in the text
+// form parsed by parseRule:
+//
+//	daily           every day
+//	weekly:mon,thu  every week on the given days
+//	monthly:15      every month on the given day
+//	every:3         3 days after the item is completed
+//
+// Weekly and monthly rules without days repeat on the day of the week or
+// of the month the item was first due, which is added to the rule once
+// known
+type rule struct {
+	kind     string
+	weekdays []time.Weekday
+	// day of the month of monthly rules, 0 when not given
+	day int
+	// days after completion of every rules
+	days int
+}
+
+// parseRule parses the recurrence rule in its text form
+func parseRule(s string) (rule, error) {
+	invalid := fmt.Errorf(
+		"Invalid recurrence %q: use daily, weekly[:mon,...], monthly[:N] or every:N",
+		s)
+
+	kind, arg := strings.ToLower(s), ""
+	if i := strings.Index(kind, ":"); i >= 0 {
+		kind, arg = kind[:i], kind[i+1:]
+	}
+
+	r := rule{kind: kind}
+
+	switch kind {
+	case recurDaily:
+		if arg != "" {
+			return rule{}, invalid
+		}
+	case recurWeekly:
+		if arg == "" {
+			break
+		}
+
+		for _, name := range strings.Split(arg, ",") {
+			d := weekday(name)
+			if d < 0 {
+				return rule{}, invalid
+			}
+			if !r.onWeekday(d) {
+				r.weekdays = append(r.weekdays, d)
+			}
+		}
+
+		sort.Slice(r.weekdays, func(i, j int) bool {
+			return r.weekdays[i] < r.weekdays[j]
+		})
+	case recurMonthly:
+		if arg == "" {
+			break
+		}
+
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > 31 {
+			return rule{}, invalid
+		}
+		r.day = n
+	case recurEvery:
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return rule{}, invalid
+		}
+		r.days = n
+	default:
+		return rule{}, invalid
+	}
+
+	return r, nil
+}
+
+// weekday returns the day of the week with the given name, or -1 when no
+// day has it
+func weekday(name string) time.Weekday {
+	for i, n := range weekdayNames {
+		if n == name {
+			return time.Weekday(i)
+		}
+	}
+
+	return -1
+}
+
+// onWeekday reports whether the rule repeats on the day of the week d
+func (r rule) onWeekday(d time.Weekday) bool {
+	for _, w := range r.weekdays {
+		if w == d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// weekdayList returns the names of the days of the week of the rule
+func (r rule) weekdayList() string {
+	names := []string{}
+	for _, d := range r.weekdays {
+		names = append(names, weekdayNames[d])
+	}
+
+	return strings.Join(names, ",")
+}
+
+// format returns the text form of the rule
+func (r rule) format() string {
+	switch {
+	case r.kind == recurWeekly && len(r.weekdays) > 0:
+		return recurWeekly + ":" + r.weekdayList()
+	case r.kind == recurMonthly && r.day > 0:
+		return recurMonthly + ":" + strconv.Itoa(r.day)
+	case r.kind == recurEvery:
+		return recurEvery + ":" + strconv.Itoa(r.days)
+	}
+
+	return r.kind
+}
+
+// String describes the rule as shown in the listing
+func (r rule) String() string {
+	switch {
+	case r.kind == recurWeekly && len(r.weekdays) > 0:
+		return "weekly on " + r.weekdayList()
+	case r.kind == recurMonthly && r.day > 0:
+		return fmt.Sprintf("monthly on day %d", r.day)
+	case r.kind == recurEvery && r.days == 1:
+		return "every day after completion"
+	case r.kind == recurEvery:
+		return fmt.Sprintf("every %d days after completion", r.days)
+	}
+
+	return r.kind
+}
+
+// next returns the date the next occurrence of an item due on due and
+// completed on done is due. The next occurrence follows both dates, so
+// items completed late aren't due again in the past
+func (r rule) next(due, done time.Time) time.Time {
+	done = day(done)
+	if r.kind == recurEvery {
+		return done.AddDate(0, 0, r.days)
+	}
+
+	// Days not given in the rule are taken from the first due date
+	first := due
+	if first.IsZero() {
+		first = done
+	}
+	r = r.anchor(first)
+
+	after := first
+	if after.Before(done) {
+		after = done
+	}
+
+	switch r.kind {
+	case recurWeekly:
+		d := after.AddDate(0, 0, 1)
+		for !r.onWeekday(d.Weekday()) {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d
+	case recurMonthly:
+		d := monthDay(after.Year(), after.Month(), r.day, after.Location())
+		if !d.After(after) {
+			d = monthDay(after.Year(), after.Month()+1, r.day, after.Location())
+		}
+		return d
+	}
+
+	return after.AddDate(0, 0, 1)
+}
+
+// anchor returns the rule with the days not given in it taken from the
+// date the item was first due. A zero date leaves the rule as it is
+func (r rule) anchor(first time.Time) rule {
+	switch {
+	case first.IsZero():
+	case r.kind == recurWeekly && len(r.weekdays) == 0:
+		r.weekdays = []time.Weekday{first.Weekday()}
+	case r.kind == recurMonthly && r.day == 0:
+		r.day = first.Day()
+	}
+
+	return r
+}
+
+// monthDay returns the day n of the month, or its last day for months
+// shorter than n days
+func monthDay(y int, m time.Month, n int, loc *time.Location) time.Time {
+	if last := time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day(); n > last {
+		n = last
+	}
+
+	return time.Date(y, m, n, 0, 0, 0, 0, loc)
+}
+
+// SetRecur sets the recurrence rule of the item with the given ID. An
+// empty rule removes it
+func (l *List) SetRecur(id int, recur string) error {
+	r := rule{}
+	if recur != "" {
+		var err error
+		if r, err = parseRule(recur); err != nil {
+			return err
+		}
+	}
+
+	i, err := l.find(id)
+	if err != nil {
+		return err
+	}
+
+	// Rules without days keep the ones of the due date, so later
+	// occurrences don't move to the end of the months shorter than it
+	if recur != "" {
+		recur = r.anchor((*l)[i].Due).format()
+	}
+
+	(*l)[i].Recur = recur
+
+	return nil
+}
+
+// nextOccurrence returns the item that follows the recurring item t,
+// completed on done. Its rule keeps the days of t, taken from the dates t
+// was due or completed when the rule has none
+func (l *List) nextOccurrence(t item, r rule, done time.Time) item {
+	first := t.Due
+	if first.IsZero() {
+		first = day(done)
+	}
+	r = r.anchor(first)
+
+	n := item{
+		ID:        l.nextID(),
+		Task:      t.Task,
+		CreatedAt: done,
+		Priority:  t.Priority,
+		Due:       r.next(t.Due, done),
+		Recur:     r.format(),
+		Parent:    t.Parent,
+		unsaved:   true,
+	}
+	if t.Parent != 0 {
+		n.Sub = l.nextSub(t.Parent)
+	}
+	n.parseTask()
+
+	return n
+}
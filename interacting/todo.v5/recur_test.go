@@ -0,0 +1,194 @@
+package todo_test
+
+import (
+  "strings"
+  "testing"
+  "time"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// date returns the date in the local time zone
+func date(y int, m time.Month, d int) time.Time {
+  return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// TestSetRecur tests that rules are validated and saved in their
+// canonical form
+func TestSetRecur(t *testing.T) {
+  testCases := []struct {
+    recur  string
+    exp    string
+    expErr bool
+  }{
+    {recur: "daily", exp: "daily"},
+    {recur: "Weekly:THU,mon,thu", exp: "weekly:mon,thu"},
+    {recur: "weekly", exp: "weekly"},
+    {recur: "monthly:15", exp: "monthly:15"},
+    {recur: "every:3", exp: "every:3"},
+    {recur: "", exp: ""},
+    {recur: "daily:2", expErr: true},
+    {recur: "weekly:someday", expErr: true},
+    {recur: "monthly:32", expErr: true},
+    {recur: "every", expErr: true},
+    {recur: "yearly", expErr: true},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.recur, func(t *testing.T) {
+      l := todo.List{}
+      l.Add("Rotate keys")
+
+      err := l.SetRecur(1, tc.recur)
+      if tc.expErr {
+        if err == nil || !strings.Contains(err.Error(), "Invalid recurrence") {
+          t.Errorf("Expected invalid recurrence error, got %v instead.", err)
+        }
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if l[0].Recur != tc.exp {
+        t.Errorf("Expected %q, got %q instead.", tc.exp, l[0].Recur)
+      }
+    })
+  }
+}
+
+// TestCompleteRecurring tests the due date of the next occurrence of a
+// recurring item. 2100-01-04 is a Monday
+func TestCompleteRecurring(t *testing.T) {
+  today := date(time.Now().Date())
+
+  testCases := []struct {
+    name  string
+    recur string
+    due   time.Time
+    exp   time.Time
+  }{
+    {name: "Daily", recur: "daily", due: date(2100, 1, 4),
+      exp: date(2100, 1, 5)},
+    {name: "DailyLate", recur: "daily", due: date(2000, 1, 1),
+      exp: today.AddDate(0, 0, 1)},
+    {name: "Weekdays", recur: "weekly:mon,thu", due: date(2100, 1, 4),
+      exp: date(2100, 1, 7)},
+    {name: "WeekdaysWrap", recur: "weekly:mon,thu", due: date(2100, 1, 7),
+      exp: date(2100, 1, 11)},
+    {name: "Weekly", recur: "weekly", due: date(2100, 1, 4),
+      exp: date(2100, 1, 11)},
+    {name: "MonthlyDay", recur: "monthly:10", due: date(2100, 1, 4),
+      exp: date(2100, 1, 10)},
+    {name: "MonthlyShort", recur: "monthly:31", due: date(2100, 1, 31),
+      exp: date(2100, 2, 28)},
+    {name: "Monthly", recur: "monthly", due: date(2100, 1, 15),
+      exp: date(2100, 2, 15)},
+    {name: "Every", recur: "every:3", due: date(2100, 1, 4),
+      exp: today.AddDate(0, 0, 3)},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      l := todo.List{}
+      l.Add("Review dashboards +ops")
+      l.SetPriority(1, "B")
+      l.SetDue(1, tc.due)
+      if err := l.SetRecur(1, tc.recur); err != nil {
+        t.Fatal(err)
+      }
+
+      if err := l.Complete(1); err != nil {
+        t.Fatal(err)
+      }
+
+      if len(l) != 2 {
+        t.Fatalf("Expected %d items, got %d instead.", 2, len(l))
+      }
+
+      n := l[1]
+      if n.ID != 2 || n.Done || n.Task != l[0].Task || n.Priority != "B" ||
+        n.Recur != l[0].Recur || n.Projects[0] != "ops" {
+        t.Errorf("Unexpected next occurrence %+v.", n)
+      }
+
+      if !n.Due.Equal(tc.exp) {
+        t.Errorf("Expected due %s, got %s instead.", tc.exp.Format(todo.DateFormat),
+          n.Due.Format(todo.DateFormat))
+      }
+
+      // Completing the item again doesn't repeat it twice
+      if err := l.Complete(1); err != nil {
+        t.Fatal(err)
+      }
+      if len(l) != 2 {
+        t.Errorf("Expected %d items, got %d instead.", 2, len(l))
+      }
+    })
+  }
+}
+
+// TestCompleteRecurringKeepsDay tests that monthly occurrences move back
+// to their day after months shorter than it
+func TestCompleteRecurringKeepsDay(t *testing.T) {
+  testCases := []struct {
+    name string
+    // setDue sets the due date before the rule
+    setDue   bool
+    expRecur string
+  }{
+    {name: "DueFirst", setDue: true, expRecur: "monthly:31"},
+    {name: "RuleFirst", expRecur: "monthly:31"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      l := todo.List{}
+      l.Add("Pay rent")
+      if tc.setDue {
+        l.SetDue(1, date(2100, 1, 31))
+      }
+      if err := l.SetRecur(1, "monthly"); err != nil {
+        t.Fatal(err)
+      }
+      if !tc.setDue {
+        l.SetDue(1, date(2100, 1, 31))
+      }
+
+      for id, exp := range []time.Time{date(2100, 2, 28), date(2100, 3, 31),
+        date(2100, 4, 30)} {
+        if err := l.Complete(id + 1); err != nil {
+          t.Fatal(err)
+        }
+
+        n := l[len(l)-1]
+        if !n.Due.Equal(exp) || n.Recur != tc.expRecur {
+          t.Errorf("Expected due %s repeating %s, got %s repeating %s instead.",
+            exp.Format(todo.DateFormat), tc.expRecur,
+            n.Due.Format(todo.DateFormat), n.Recur)
+        }
+      }
+    })
+  }
+}
+
+// TestStringRecur tests that the listing shows the recurrence rules
+func TestStringRecur(t *testing.T) {
+  l := todo.List{}
+  l.Add("Rotate keys")
+  l.Add("Review dashboards")
+  l.Add("Water plants")
+  l.SetDue(1, date(2100, 1, 4))
+  l.SetRecur(1, "weekly:mon,thu")
+  l.SetRecur(2, "monthly:1")
+  l.SetRecur(3, "every:2")
+
+  exp := "  1: Rotate keys (due 2100-01-04) (repeats weekly on mon,thu)\n" +
+    "  2: Review dashboards (repeats monthly on day 1)\n" +
+    "  3: Water plants (repeats every 2 days after completion)\n"
+
+  if l.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, l.String())
+  }
+}
@@ -0,0 +1,249 @@
+package todo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Store saves and loads the items of a List
+type Store interface {
+	// Load returns the items saved, or an empty List when nothing was
+	// saved yet
+	Load() (List, error)
+	// Save replaces the items saved with the items of l
+	Save(l List) error
+}
+
+// idKeeper is a Store that keeps the highest ID it ever gave to an item,
+// even after the item is deleted, so SaveTo doesn't give it again
+type idKeeper interface {
+	// lastID returns the highest ID given, 0 when nothing was saved yet
+	lastID() (int, error)
+}
+
+// Backends maps the name of every storage backend to the extension of the
+// files it's chosen for
+var Backends = map[string]string{
+	"json":    ".json",
+	"todotxt": ".txt",
+	"csv":     ".csv",
+	"db":      ".db",
+}
+
+// NewStore returns the Store for filename using the named backend. When
+// backend is empty it's chosen by the file extension, falling back to
+// JSON
+func NewStore(filename, backend string) (Store, error) {
+	if backend == "" {
+		backend = "json"
+		for name, ext := range Backends {
+			if filepath.Ext(filename) == ext {
+				backend = name
+			}
+		}
+	}
+
+	switch backend {
+	case "json":
+		return &jsonStore{filename: filename}, nil
+	case "todotxt":
+		return &todoTxtStore{filename: filename}, nil
+	case "csv":
+		return &csvStore{filename: filename}, nil
+	case "db":
+		return &dbStore{filename: filename}, nil
+	}
+
+	return nil, fmt.Errorf("Storage backend not supported: %s", backend)
+}
+
+// lastIDHeader starts the line holding the highest ID given in the text
+// formats. The line comes first and is saved only while that ID is higher
+// than the IDs of the items, after the newest ones are deleted
+const lastIDHeader = "# last_id: "
+
+// formatLastID returns the line holding last for the items of l, or an
+// empty string when their IDs don't need it
+func formatLastID(l List, last int) string {
+	if last <= l.maxID() {
+		return ""
+	}
+
+	return lastIDHeader + strconv.Itoa(last) + "\n"
+}
+
+// parseLastID returns the highest ID given held in the first line of file
+// and the rest of file. Files without the line give 0 and all of file
+func parseLastID(file []byte) (int, []byte) {
+	line, rest := file, []byte{}
+	if i := bytes.IndexByte(file, '\n'); i >= 0 {
+		line, rest = file[:i], file[i+1:]
+	}
+
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte(lastIDHeader)) {
+		return 0, file
+	}
+
+	last, err := strconv.Atoi(string(line[len(lastIDHeader):]))
+	if err != nil {
+		return 0, file
+	}
+
+	return last, rest
+}
+
+// readFile returns the contents of filename, or nil when it doesn't
+// exist yet
+func readFile(filename string) ([]byte, error) {
+	file, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// writeFile replaces filename with data atomically. Data is written to a
+// temporary file in the same directory, synced to disk and renamed over
+// filename, so a crash leaves either the old or the new contents. The
+// permissions of filename are kept when it exists
+func writeFile(filename string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tf, err := ioutil.TempFile(filepath.Dir(filename),
+		filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	// Nothing is left to remove once it's renamed
+	defer os.Remove(tf.Name())
+
+	if _, err := tf.Write(data); err != nil {
+		tf.Close()
+		return err
+	}
+
+	if err := tf.Chmod(perm); err != nil {
+		tf.Close()
+		return err
+	}
+
+	if err := tf.Sync(); err != nil {
+		tf.Close()
+		return err
+	}
+
+	if err := tf.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tf.Name(), filename); err != nil {
+		return err
+	}
+
+	syncDir(filepath.Dir(filename))
+
+	return nil
+}
+
+// syncDir syncs the directory dir so a file renamed into it survives a
+// crash. Not every system supports it, so errors are ignored
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	d.Sync()
+}
+
+// jsonStore saves the List as a JSON object with its items and the
+// highest ID given. Files saved before hold a JSON array of items
+type jsonStore struct {
+	filename string
+}
+
+// jsonList is the JSON object the List is saved as
+type jsonList struct {
+	LastID int  `json:"last_id"`
+	Items  List `json:"items"`
+}
+
+func (s *jsonStore) Load() (List, error) {
+	jl, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return jl.Items, nil
+}
+
+func (s *jsonStore) Save(l List) error {
+	last, err := s.lastID()
+	if err != nil {
+		return err
+	}
+
+	if max := l.maxID(); max > last {
+		last = max
+	}
+
+	js, err := json.Marshal(jsonList{LastID: last, Items: l})
+	if err != nil {
+		return err
+	}
+
+	return writeFile(s.filename, js)
+}
+
+func (s *jsonStore) lastID() (int, error) {
+	jl, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+
+	if max := jl.Items.maxID(); max > jl.LastID {
+		return max, nil
+	}
+
+	return jl.LastID, nil
+}
+
+// read decodes the file, in the current format or as the array of items
+// saved before
+func (s *jsonStore) read() (jsonList, error) {
+	jl := jsonList{Items: List{}}
+
+	file, err := readFile(s.filename)
+	if err != nil || len(bytes.TrimSpace(file)) == 0 {
+		return jl, err
+	}
+
+	if bytes.TrimSpace(file)[0] == '[' {
+		err = json.Unmarshal(file, &jl.Items)
+	} else {
+		err = json.Unmarshal(file, &jl)
+	}
+	if err != nil {
+		return jsonList{}, err
+	}
+
+	if jl.Items == nil {
+		jl.Items = List{}
+	}
+
+	return jl, nil
+}
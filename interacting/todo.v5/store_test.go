@@ -0,0 +1,450 @@
+package todo_test
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+  "time"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// newStoreList returns a list using every field of the items
+func newStoreList(t *testing.T) todo.List {
+  t.Helper()
+
+  l := todo.List{}
+  l.Add("Write report +work @office #q3")
+  l.Add(`Quote "this", and that`)
+  l.Add("Call the bank @phone")
+  l.Add("Plan trip +home")
+
+  l.SetPriority(1, "A")
+  l.SetPriority(2, "3")
+  l.SetPriority(4, "B")
+  l.SetDue(1, time.Date(2020, 4, 30, 0, 0, 0, 0, time.Local))
+  l.SetRecur(1, "weekly:thu,mon")
+  l.AddSub(2, "Quote the reply")
+  l.Complete(4)
+  l.Delete(3)
+
+  return l
+}
+
+// tempDir creates a temporary directory removed by the returned function
+func tempDir(t *testing.T) (string, func()) {
+  t.Helper()
+
+  dir, err := ioutil.TempDir("", "todostore")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return dir, func() { os.RemoveAll(dir) }
+}
+
+// TestStores tests saving and loading a list with every backend
+func TestStores(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  testCases := []struct {
+    file    string
+    backend string
+    // todo.txt keeps the dates of the items only
+    dates bool
+  }{
+    {file: "todo.json"},
+    {file: "todo.txt", dates: true},
+    {file: "todo.csv"},
+    {file: "todo.db"},
+    {file: "todo.list", backend: "todotxt", dates: true},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.file, func(t *testing.T) {
+      s, err := todo.NewStore(filepath.Join(dir, tc.file), tc.backend)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      // A store that was never saved is empty
+      l := todo.List{}
+      if err := l.GetFrom(s); err != nil {
+        t.Fatal(err)
+      }
+      if len(l) != 0 {
+        t.Fatalf("Expected empty list, got %d items instead.", len(l))
+      }
+
+      exp := newStoreList(t)
+      if err := exp.SaveTo(s); err != nil {
+        t.Fatal(err)
+      }
+
+      if err := l.GetFrom(s); err != nil {
+        t.Fatal(err)
+      }
+
+      if len(l) != len(exp) {
+        t.Fatalf("Expected %d items, got %d instead.", len(exp), len(l))
+      }
+
+      for i := range exp {
+        if tc.dates {
+          exp[i].CreatedAt = day(exp[i].CreatedAt)
+          exp[i].CompletedAt = day(exp[i].CompletedAt)
+        }
+
+        // Times are compared apart since their location may change
+        if !l[i].CreatedAt.Equal(exp[i].CreatedAt) ||
+          !l[i].CompletedAt.Equal(exp[i].CompletedAt) ||
+          !l[i].Due.Equal(exp[i].Due) {
+          t.Errorf("Expected times of %+v, got %+v instead.", exp[i], l[i])
+        }
+        l[i].CreatedAt, l[i].CompletedAt, l[i].Due = exp[i].CreatedAt,
+          exp[i].CompletedAt, exp[i].Due
+
+        if !reflect.DeepEqual(l[i], exp[i]) {
+          t.Errorf("Expected %+v, got %+v instead.", exp[i], l[i])
+        }
+      }
+
+      // The store keeps the highest ID given after deleting every item,
+      // numbering the items added after it when saving
+      for _, id := range []int{1, 2, 4} {
+        if err := l.Delete(id); err != nil {
+          t.Fatal(err)
+        }
+      }
+      if err := l.SaveTo(s); err != nil {
+        t.Fatal(err)
+      }
+
+      l = todo.List{}
+      if err := l.GetFrom(s); err != nil {
+        t.Fatal(err)
+      }
+
+      l.Add("Plan trip")
+      if err := l.AddSub(l[0].ID, "Book flights"); err != nil {
+        t.Fatal(err)
+      }
+      if err := l.SaveTo(s); err != nil {
+        t.Fatal(err)
+      }
+
+      if err := l.GetFrom(s); err != nil {
+        t.Fatal(err)
+      }
+      if len(l) != 2 || l[0].ID != 6 || l[1].ID != 7 || l[1].Parent != 6 {
+        t.Errorf("Expected items 6 and its subtask 7, got %+v instead.", l)
+      }
+    })
+  }
+}
+
+// day returns the start of the day of t, or the zero time
+func day(t time.Time) time.Time {
+  if t.IsZero() {
+    return t
+  }
+
+  y, m, d := t.Date()
+  return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// TestTodoTxt tests the lines of the todo.txt format
+func TestTodoTxt(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  fname := filepath.Join(dir, "todo.txt")
+  lines := "x 2020-04-20 2020-04-18 Done task +work pri:B id:7\n" +
+    "\n" +
+    "(A) 2020-04-18 Urgent @phone due:2020-04-30 rec:monthly:30 id:8\n" +
+    "Imported from another tool id:none\n"
+
+  if err := ioutil.WriteFile(fname, []byte(lines), 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  l := todo.List{}
+  if err := l.Get(fname); err != nil {
+    t.Fatal(err)
+  }
+
+  if len(l) != 3 {
+    t.Fatalf("Expected %d items, got %d instead.", 3, len(l))
+  }
+
+  if !l[0].Done || l[0].ID != 7 || l[0].Priority != "B" ||
+    l[0].Task != "Done task +work" || l[0].Projects[0] != "work" ||
+    l[0].CompletedAt.Format(todo.DateFormat) != "2020-04-20" {
+    t.Errorf("Unexpected completed item %+v.", l[0])
+  }
+
+  if l[1].Priority != "A" || l[1].Due.Format(todo.DateFormat) != "2020-04-30" ||
+    l[1].Recur != "monthly:30" ||
+    l[1].CreatedAt.Format(todo.DateFormat) != "2020-04-18" {
+    t.Errorf("Unexpected item %+v.", l[1])
+  }
+
+  // Lines without an ID get the next one
+  if l[2].ID != 9 || l[2].Task != "Imported from another tool id:none" {
+    t.Errorf("Unexpected imported item %+v.", l[2])
+  }
+
+  if err := l.Save(fname); err != nil {
+    t.Fatal(err)
+  }
+
+  saved, err := ioutil.ReadFile(fname)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  exp := "x 2020-04-20 2020-04-18 Done task +work pri:B id:7\n" +
+    "(A) 2020-04-18 Urgent @phone due:2020-04-30 rec:monthly:30 id:8\n" +
+    "Imported from another tool id:none id:9\n"
+
+  if string(saved) != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, string(saved))
+  }
+}
+
+// TestCSVInvalid tests loading CSV files that don't hold a list
+func TestCSVInvalid(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  testCases := []struct {
+    name    string
+    content string
+    errMsg  string
+  }{
+    {name: "BlankLine", content: "\n"},
+    {name: "HeaderOnly", content: "id,task,done,priority,due,created_at," +
+      "completed_at\n"},
+    {name: "ShortRecord", content: "id,task\n1,foo\n",
+      errMsg: "Invalid CSV record"},
+    {name: "InvalidID", content: "id,task,done,priority,due,created_at," +
+      "completed_at\nx,foo,false,,,,\n", errMsg: "Invalid CSV record"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      fname := filepath.Join(dir, tc.name+".csv")
+      if err := ioutil.WriteFile(fname, []byte(tc.content), 0644); err != nil {
+        t.Fatal(err)
+      }
+
+      l := todo.List{}
+      err := l.Get(fname)
+
+      if tc.errMsg != "" {
+        if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Expected error %q, got %v instead.", tc.errMsg, err)
+        }
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+      if len(l) != 0 {
+        t.Errorf("Expected empty list, got %d items instead.", len(l))
+      }
+    })
+  }
+}
+
+// TestDBStore tests that the database only grows by the items changed
+// and survives a damaged record
+func TestDBStore(t *testing.T) {
+  dir, cleanup := tempDir(t)
+  defer cleanup()
+
+  fname := filepath.Join(dir, "todo.db")
+  s, err := todo.NewStore(fname, "")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  l := todo.List{}
+  for i := 0; i < 1000; i++ {
+    l.Add(strings.Repeat("task ", 10))
+  }
+  if err := l.SaveTo(s); err != nil {
+    t.Fatal(err)
+  }
+
+  size := fileSize(t, fname)
+
+  // Completing an item appends a single record
+  l.Complete(500)
+  if err := l.SaveTo(s); err != nil {
+    t.Fatal(err)
+  }
+
+  grown := fileSize(t, fname) - size
+  if grown <= 0 || grown > 512 {
+    t.Errorf("Expected a single record, the file grew %d bytes.", grown)
+  }
+
+  // Writes that didn't finish leave a damaged record at the end
+  f, err := os.OpenFile(fname, os.O_APPEND|os.O_WRONLY, 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  f.Write([]byte("P\x05\xff"))
+  f.Close()
+
+  l.Delete(1)
+  if err := l.SaveTo(s); err != nil {
+    t.Fatal(err)
+  }
+
+  res := todo.List{}
+  if err := res.GetFrom(s); err != nil {
+    t.Fatal(err)
+  }
+
+  if len(res) != 999 || res[0].ID != 2 || !res[498].Done {
+    t.Errorf("Unexpected items after damaged record: %d items.", len(res))
+  }
+
+  // Deleting most items compacts the file
+  res = res[:10]
+  if err := res.SaveTo(s); err != nil {
+    t.Fatal(err)
+  }
+
+  if compacted := fileSize(t, fname); compacted >= size/10 {
+    t.Errorf("Expected compacted file smaller than %d, got %d instead.",
+      size/10, compacted)
+  }
+
+  if err := l.GetFrom(s); err != nil || len(l) != 10 {
+    t.Errorf("Expected %d items after compacting, got %d: %v", 10, len(l),
+      err)
+  }
+
+  // Adding an item appends a single record, keeping the IDs of the items
+  // deleted
+  size = fileSize(t, fname)
+  l.Add("task")
+  if err := l.SaveTo(s); err != nil {
+    t.Fatal(err)
+  }
+
+  if id := l[len(l)-1].ID; id != 1001 {
+    t.Errorf("Expected ID %d, got %d instead.", 1001, id)
+  }
+
+  grown = fileSize(t, fname) - size
+  if grown <= 0 || grown > 512 {
+    t.Errorf("Expected a single record, the file grew %d bytes.", grown)
+  }
+}
+
+// fileSize returns the size of the file fname
+func fileSize(t *testing.T, fname string) int64 {
+  t.Helper()
+
+  info, err := os.Stat(fname)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  return info.Size()
+}
+
+// TestNewStoreUnsupported tests an unknown backend
+func TestNewStoreUnsupported(t *testing.T) {
+  _, err := todo.NewStore("todo.json", "yaml")
+
+  if err == nil || !strings.Contains(err.Error(),
+    "Storage backend not supported: yaml") {
+    t.Errorf("Unexpected error: %v", err)
+  }
+}
+
+// TestConcurrentAdds tests that adds holding the lock don't lose items
+func TestConcurrentAdds(t *testing.T) {
+  for _, file := range []string{"todo.json", "todo.db"} {
+    t.Run(file, func(t *testing.T) {
+      dir, cleanup := tempDir(t)
+      defer cleanup()
+
+      fname := filepath.Join(dir, file)
+      workers, adds := 10, 10
+
+      errCh := make(chan error, workers)
+      for i := 0; i < workers; i++ {
+        go func() {
+          for j := 0; j < adds; j++ {
+            if err := lockedAdd(fname, "New Task"); err != nil {
+              errCh <- err
+              return
+            }
+          }
+          errCh <- nil
+        }()
+      }
+
+      for i := 0; i < workers; i++ {
+        if err := <-errCh; err != nil {
+          t.Fatal(err)
+        }
+      }
+
+      l := todo.List{}
+      if err := l.Get(fname); err != nil {
+        t.Fatal(err)
+      }
+
+      if len(l) != workers*adds {
+        t.Errorf("Expected %d items, got %d instead.", workers*adds, len(l))
+      }
+
+      for i, item := range l {
+        if item.ID != i+1 {
+          t.Errorf("Expected ID %d, got %d instead.", i+1, item.ID)
+        }
+      }
+
+      // Saving leaves no temporary files behind
+      files, err := ioutil.ReadDir(dir)
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if len(files) != 2 {
+        t.Errorf("Expected the list and its lock only, got %d files.",
+          len(files))
+      }
+    })
+  }
+}
+
+// lockedAdd adds a task to the list saved in fname holding its lock
+func lockedAdd(fname, task string) error {
+  lock, err := todo.Lock(fname)
+  if err != nil {
+    return err
+  }
+  defer lock.Unlock()
+
+  l := todo.List{}
+  if err := l.Get(fname); err != nil {
+    return err
+  }
+
+  l.Add(task)
+
+  return l.Save(fname)
+}
@@ -0,0 +1,182 @@
+package todo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddSub creates a new todo item as a subtask of the item with the ID
+// parent. Subtasks are numbered in order within their parent, so the
+// second subtask of item 3 has the path 3.2
+func (l *List) AddSub(parent int, task string) error {
+	if _, err := l.find(parent); err != nil {
+		return err
+	}
+
+	sub := l.nextSub(parent)
+
+	l.Add(task)
+
+	t := &(*l)[len(*l)-1]
+	t.Parent = parent
+	t.Sub = sub
+
+	return nil
+}
+
+// Resolve returns the ID of the item at path. A path is the ID of an item
+// followed by the numbers of the subtasks below it, like 3.2 for the
+// second subtask of item 3
+func (l *List) Resolve(path string) (int, error) {
+	parts := strings.Split(path, ".")
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("Invalid item %q: use an ID or a path like 3.2",
+			path)
+	}
+
+	if _, err := l.find(id); err != nil {
+		return 0, err
+	}
+
+	for _, p := range parts[1:] {
+		sub, err := strconv.Atoi(p)
+		if err != nil || sub < 1 {
+			return 0, fmt.Errorf("Invalid item %q: use an ID or a path like 3.2",
+				path)
+		}
+
+		next := 0
+		for _, t := range *l {
+			if t.Parent == id && t.Sub == sub {
+				next = t.ID
+				break
+			}
+		}
+
+		if next == 0 {
+			return 0, notExistError(path)
+		}
+		id = next
+	}
+
+	return id, nil
+}
+
+// CompleteOptions control how completing an item changes its subtasks
+// and parent
+type CompleteOptions struct {
+	// Cascade completes every subtask below the item too
+	Cascade bool
+	// RollUp completes the parent of the item once all its subtasks are
+	// done, and so on up the tree
+	RollUp bool
+}
+
+// CompleteWith marks the ToDo item with the given ID as completed, like
+// Complete, and its subtasks and parents as set in opts
+func (l *List) CompleteWith(id int, opts CompleteOptions) error {
+	i, err := l.find(id)
+	if err != nil {
+		return err
+	}
+	parent := (*l)[i].Parent
+
+	// Subtasks are taken before completing the item so the next
+	// occurrences of recurring ones stay open
+	subs := l.subtasks(id)
+
+	if err := l.complete(i, time.Now()); err != nil {
+		return err
+	}
+
+	if opts.Cascade {
+		for _, s := range subs {
+			if err := l.CompleteWith(s, CompleteOptions{Cascade: true}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !opts.RollUp || parent == 0 {
+		return nil
+	}
+
+	p, err := l.find(parent)
+	if err != nil || (*l)[p].Done {
+		return nil
+	}
+
+	for _, s := range l.subtasks(parent) {
+		if j, _ := l.find(s); !(*l)[j].Done {
+			return nil
+		}
+	}
+
+	return l.CompleteWith(parent, CompleteOptions{RollUp: true})
+}
+
+// subtasks returns the IDs of the subtasks of the item with the given ID,
+// in list order
+func (l *List) subtasks(id int) []int {
+	ids := []int{}
+	for _, t := range *l {
+		if t.Parent == id {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	return ids
+}
+
+// subtree returns the IDs of the item with the given ID and every subtask
+// below it
+func (l *List) subtree(id int) map[int]bool {
+	ids := map[int]bool{id: true}
+
+	for _, s := range l.subtasks(id) {
+		for d := range l.subtree(s) {
+			ids[d] = true
+		}
+	}
+
+	return ids
+}
+
+// nextSub returns the number following the highest one of the subtasks
+// of the item with the ID parent
+func (l *List) nextSub(parent int) int {
+	max := 0
+	for _, t := range *l {
+		if t.Parent == parent && t.Sub > max {
+			max = t.Sub
+		}
+	}
+
+	return max + 1
+}
+
+// tree returns the positions of the items at the top of the list and of
+// the subtasks of every item, keeping the list order. Subtasks whose
+// parent isn't in the list, like in filtered lists, are at the top
+func (l *List) tree() ([]int, map[int][]int) {
+	ids := map[int]bool{}
+	for _, t := range *l {
+		ids[t.ID] = true
+	}
+
+	top := []int{}
+	subs := map[int][]int{}
+	for i, t := range *l {
+		if t.Parent != 0 && ids[t.Parent] {
+			subs[t.Parent] = append(subs[t.Parent], i)
+		} else {
+			top = append(top, i)
+		}
+	}
+
+	return top, subs
+}
@@ -0,0 +1,177 @@
+package todo_test
+
+import (
+  "strings"
+  "testing"
+
+  "pragprog.com/rggo/interacting/todo"
+)
+
+// newTree returns a list with a trip to plan in three steps, the first of
+// them with two steps of its own
+func newTree(t *testing.T) todo.List {
+  t.Helper()
+
+  l := todo.List{}
+  l.Add("Clean house")
+  l.Add("Plan trip")
+
+  for _, s := range []struct {
+    parent int
+    task   string
+  }{
+    {2, "Book flights"},
+    {2, "Book hotel"},
+    {2, "Pack"},
+    {3, "Compare prices"},
+    {3, "Pay"},
+  } {
+    if err := l.AddSub(s.parent, s.task); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  return l
+}
+
+// TestResolve tests finding items by ID and path
+func TestResolve(t *testing.T) {
+  l := newTree(t)
+
+  testCases := []struct {
+    path   string
+    exp    int
+    errMsg string
+  }{
+    {path: "2", exp: 2},
+    {path: "2.2", exp: 4},
+    {path: "2.1.2", exp: 7},
+    {path: "7", exp: 7},
+    {path: "2.4", errMsg: "Item 2.4 does not exist"},
+    {path: "9", errMsg: "Item 9 does not exist"},
+    {path: "2.x", errMsg: "Invalid item"},
+    {path: "", errMsg: "Invalid item"},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.path, func(t *testing.T) {
+      id, err := l.Resolve(tc.path)
+
+      if tc.errMsg != "" {
+        if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+          t.Errorf("Expected error %q, got %v instead.", tc.errMsg, err)
+        }
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if id != tc.exp {
+        t.Errorf("Expected ID %d, got %d instead.", tc.exp, id)
+      }
+    })
+  }
+
+  if err := l.AddSub(9, "Orphan"); err == nil {
+    t.Errorf("Expected error adding a subtask to a missing item.")
+  }
+}
+
+// TestStringTree tests rendering subtasks as an indented tree
+func TestStringTree(t *testing.T) {
+  l := newTree(t)
+  l.Complete(6)
+
+  exp := "  1: Clean house\n" +
+    "  2: Plan trip (0/3)\n" +
+    "    2.1: Book flights (1/2)\n" +
+    "    X 2.1.1: Compare prices\n" +
+    "      2.1.2: Pay\n" +
+    "    2.2: Book hotel\n" +
+    "    2.3: Pack\n"
+
+  if l.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, l.String())
+  }
+
+  // Subtasks without their parent in a filtered list keep their ID
+  filtered := l.FilterBy("", "", "")[5:]
+  exp = "X 6: Compare prices\n  7: Pay\n"
+
+  if filtered.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, filtered.String())
+  }
+}
+
+// TestCompleteWith tests completing subtasks and parents
+func TestCompleteWith(t *testing.T) {
+  testCases := []struct {
+    name string
+    opts todo.CompleteOptions
+    ids  []int
+    exp  []int
+  }{
+    {name: "Plain", ids: []int{3}, exp: []int{3}},
+    {name: "Cascade", opts: todo.CompleteOptions{Cascade: true},
+      ids: []int{2}, exp: []int{2, 3, 4, 5, 6, 7}},
+    {name: "RollUp", opts: todo.CompleteOptions{RollUp: true},
+      ids: []int{6, 7}, exp: []int{3, 6, 7}},
+    {name: "RollUpAll", opts: todo.CompleteOptions{RollUp: true},
+      ids: []int{6, 7, 4, 5}, exp: []int{2, 3, 4, 5, 6, 7}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      l := newTree(t)
+
+      for _, id := range tc.ids {
+        if err := l.CompleteWith(id, tc.opts); err != nil {
+          t.Fatal(err)
+        }
+      }
+
+      done := []int{}
+      for _, i := range l {
+        if i.Done {
+          done = append(done, i.ID)
+        }
+      }
+
+      if len(done) != len(tc.exp) {
+        t.Fatalf("Expected done items %v, got %v instead.", tc.exp, done)
+      }
+      for i := range tc.exp {
+        if done[i] != tc.exp[i] {
+          t.Errorf("Expected done items %v, got %v instead.", tc.exp, done)
+          break
+        }
+      }
+    })
+  }
+}
+
+// TestDeleteSubtree tests that deleting an item deletes its subtasks
+func TestDeleteSubtree(t *testing.T) {
+  l := newTree(t)
+
+  if err := l.Delete(3); err != nil {
+    t.Fatal(err)
+  }
+
+  exp := "  1: Clean house\n" +
+    "  2: Plan trip (0/2)\n" +
+    "    2.2: Book hotel\n" +
+    "    2.3: Pack\n"
+
+  if l.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, l.String())
+  }
+
+  // New subtasks follow the highest number of their parent
+  l.AddSub(2, "Buy sunscreen")
+  if id, err := l.Resolve("2.4"); err != nil || id != 6 {
+    t.Errorf("Expected ID 6 at 2.4, got %d, %v instead.", id, err)
+  }
+}
@@ -1,107 +1,551 @@
 package todo
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // item struct represents a ToDo item
 type item struct {
+	// ID identifies the item even after other items are deleted
+	ID          int
 	Task        string
 	Done        bool
 	CreatedAt   time.Time
 	CompletedAt time.Time
+	// Priority is a letter from A to Z or a number from 1 to 5, empty
+	// when the item has none
+	Priority string
+	// Due is the date the item is due, zero when it has none
+	Due time.Time
+	// Recur is the rule completed items repeat with, empty when they
+	// don't repeat. See parseRule
+	Recur string
+	// Parent is the ID of the item this one is a subtask of, 0 for items
+	// at the top of the list. Sub numbers the subtasks of a parent
+	Parent int
+	Sub    int
+	// +project, @context and #tag names found in Task, without the sign
+	Projects []string
+	Contexts []string
+	Tags     []string
+	// unsaved marks the items added since the List was loaded or saved.
+	// Their IDs are final once saved. See SaveTo
+	unsaved bool
+}
+
+// Signs that start project, context and tag names in the task text
+const (
+	projectSign = '+'
+	contextSign = '@'
+	tagSign     = '#'
+)
+
+// parseTask fills the projects, contexts and tags of the item from the
+// words of its task that start with their sign, in todo.txt style
+func (t *item) parseTask() {
+	t.Projects, t.Contexts, t.Tags = nil, nil, nil
+
+	for _, w := range strings.Fields(t.Task) {
+		if len(w) < 2 {
+			continue
+		}
+
+		name := w[1:]
+		switch w[0] {
+		case projectSign:
+			t.Projects = appendUnique(t.Projects, name)
+		case contextSign:
+			t.Contexts = appendUnique(t.Contexts, name)
+		case tagSign:
+			t.Tags = appendUnique(t.Tags, name)
+		}
+	}
+}
+
+// appendUnique appends name to names unless it's already there
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+
+	return append(names, name)
+}
+
+// has reports whether names includes name. An empty name is always
+// included
+func has(names []string, name string) bool {
+	if name == "" {
+		return true
+	}
+
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overdue reports whether the item isn't done and was due before the
+// day of now
+func (t item) overdue(now time.Time) bool {
+	return !t.Done && !t.Due.IsZero() && t.Due.Before(day(now))
+}
+
+// day returns the start of the day of t
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
 // List represents a list of ToDo items
 type List []item
 
+// DateFormat is the layout of due dates
+const DateFormat = "2006-01-02"
+
 //String prints outs a formatted list
 //Implements the fmt.Stringer interface
+//Overdue items are highlighted with a !
+//Subtasks are indented below their parent, which shows how
+//many of them are done
 func (l *List) String() string {
 	formatted := ""
+	now := time.Now()
+	ls := *l
+	top, subs := l.tree()
+
+	var format func(i int, label, indent string)
+	format = func(i int, label, indent string) {
+		t := ls[i]
 
-	for k, t := range *l {
 		prefix := "  "
 		if t.Done {
 			prefix = "X "
 		}
+		if t.overdue(now) {
+			prefix = "! "
+		}
+
+		task := t.Task
+		if len(subs[t.ID]) > 0 {
+			done := 0
+			for _, s := range subs[t.ID] {
+				if ls[s].Done {
+					done++
+				}
+			}
+			task = fmt.Sprintf("%s (%d/%d)", task, done, len(subs[t.ID]))
+		}
+		if t.Priority != "" {
+			task = fmt.Sprintf("(%s) %s", t.Priority, task)
+		}
+		if !t.Due.IsZero() {
+			task = fmt.Sprintf("%s (due %s)", task, t.Due.Format(DateFormat))
+		}
+		if r, err := parseRule(t.Recur); err == nil {
+			task = fmt.Sprintf("%s (repeats %s)", task, r)
+		}
+
+		formatted += fmt.Sprintf("%s%s%s: %s\n", indent, prefix, label, task)
+
+		for _, s := range subs[t.ID] {
+			format(s, fmt.Sprintf("%s.%d", label, ls[s].Sub), indent+"  ")
+		}
+	}
 
-		// Adjust the item number k to print numbers starting from 1 instead of 0
-		formatted += fmt.Sprintf("%s%d: %s\n", prefix, k+1, t.Task)
+	for _, i := range top {
+		format(i, strconv.Itoa(ls[i].ID), "")
 	}
 
 	return formatted
 }
 
-// Add creates a new todo item and appends it to the list
+// Add creates a new todo item and appends it to the list. The item gets
+// the ID following the highest one in the list, which may change when
+// the List is saved. See SaveTo
 func (l *List) Add(task string) {
 	t := item{
+		ID:          l.nextID(),
 		Task:        task,
 		Done:        false,
 		CreatedAt:   time.Now(),
 		CompletedAt: time.Time{},
+		unsaved:     true,
 	}
+	t.parseTask()
 
 	*l = append(*l, t)
 }
 
-// Complete method marks a ToDo item as completed by
-// setting Done = true and CompletedAt to the current time
-func (l *List) Complete(i int) error {
+// Complete method marks the ToDo item with the given ID as completed by
+// setting Done = true and CompletedAt to the current time. Completing a
+// recurring item appends its next occurrence to the list
+func (l *List) Complete(id int) error {
+	return l.CompleteWith(id, CompleteOptions{})
+}
+
+// complete marks the item at position i as completed on now
+func (l *List) complete(i int, now time.Time) error {
 	ls := *l
-	if i <= 0 || i > len(ls) {
-		return fmt.Errorf("Item %d does not exist", i)
+	t := ls[i]
+
+	ls[i].Done = true
+	ls[i].CompletedAt = now
+
+	if t.Done || t.Recur == "" {
+		return nil
+	}
+
+	r, err := parseRule(t.Recur)
+	if err != nil {
+		return err
 	}
 
-	// Adjusting index for 0 based index
-	ls[i-1].Done = true
-	ls[i-1].CompletedAt = time.Now()
+	*l = append(ls, l.nextOccurrence(t, r, now))
 
 	return nil
 }
 
-// Delete method deletes a ToDo item from the list
-func (l *List) Delete(i int) error {
+// Delete method deletes the ToDo item with the given ID from the list,
+// with every subtask below it
+func (l *List) Delete(id int) error {
+	if _, err := l.find(id); err != nil {
+		return err
+	}
+
+	ids := l.subtree(id)
+
+	kept := List{}
+	for _, t := range *l {
+		if !ids[t.ID] {
+			kept = append(kept, t)
+		}
+	}
+	*l = kept
+
+	return nil
+}
+
+// SetPriority sets the priority of the item with the given ID. It's a
+// letter from A to Z or a number from 1 to 5, where A and 1 are the
+// highest. An empty priority removes it
+func (l *List) SetPriority(id int, priority string) error {
+	priority = strings.ToUpper(priority)
+
+	valid := len(priority) == 1 &&
+		(priority[0] >= 'A' && priority[0] <= 'Z' ||
+			priority[0] >= '1' && priority[0] <= '5')
+	if priority != "" && !valid {
+		return fmt.Errorf("Invalid priority %q: use A-Z or 1-5", priority)
+	}
+
+	i, err := l.find(id)
+	if err != nil {
+		return err
+	}
+
+	(*l)[i].Priority = priority
+
+	return nil
+}
+
+// SetDue sets the date the item with the given ID is due. A zero date
+// removes it
+func (l *List) SetDue(id int, due time.Time) error {
+	i, err := l.find(id)
+	if err != nil {
+		return err
+	}
+
+	if !due.IsZero() {
+		due = day(due)
+	}
+	(*l)[i].Due = due
+
+	return nil
+}
+
+// Sort orders the items by "priority" or "due" date. Numeric priorities
+// go before letters and items without a priority or a due date go last.
+// Items that compare equal keep their order
+func (l *List) Sort(by string) error {
 	ls := *l
-	if i <= 0 || i > len(ls) {
-		return fmt.Errorf("Item %d does not exist", i)
+
+	var less func(a, b item) bool
+
+	switch by {
+	case "priority":
+		less = func(a, b item) bool {
+			return a.Priority != "" &&
+				(b.Priority == "" || a.Priority < b.Priority)
+		}
+	case "due":
+		less = func(a, b item) bool {
+			return !a.Due.IsZero() && (b.Due.IsZero() || a.Due.Before(b.Due))
+		}
+	default:
+		return fmt.Errorf("Cannot sort by %q: use priority or due", by)
 	}
 
-	// Adjusting index for 0 based index
-	*l = append(ls[:i-1], ls[i:]...)
+	sort.SliceStable(ls, func(i, j int) bool {
+		return less(ls[i], ls[j])
+	})
 
 	return nil
 }
 
-// Save method encodes the List as JSON and saves it
-// using the provided file name
+// FilterDue returns the items not done yet that are due "today", within
+// a "week" from today or "overdue", as of now
+func (l *List) FilterDue(when string, now time.Time) (List, error) {
+	today := day(now)
+
+	var match func(t item) bool
+
+	switch when {
+	case "today":
+		match = func(t item) bool { return t.Due.Equal(today) }
+	case "week":
+		match = func(t item) bool {
+			return !t.Due.Before(today) && t.Due.Before(today.AddDate(0, 0, 7))
+		}
+	case "overdue":
+		match = func(t item) bool { return t.overdue(now) }
+	default:
+		return nil, fmt.Errorf("Invalid due filter %q: use today, week or overdue",
+			when)
+	}
+
+	filtered := List{}
+	for _, t := range *l {
+		if !t.Done && !t.Due.IsZero() && match(t) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return filtered, nil
+}
+
+// FilterBy returns the items in the given project and context and with
+// the given tag. Names may start with their sign and empty names match
+// every item
+func (l *List) FilterBy(project, context, tag string) List {
+	project = strings.TrimPrefix(project, string(projectSign))
+	context = strings.TrimPrefix(context, string(contextSign))
+	tag = strings.TrimPrefix(tag, string(tagSign))
+
+	filtered := List{}
+	for _, t := range *l {
+		if has(t.Projects, project) && has(t.Contexts, context) &&
+			has(t.Tags, tag) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return filtered
+}
+
+// NameCount holds the number of open and done items of a project,
+// context or tag
+type NameCount struct {
+	// Name starts with the sign of the project, context or tag
+	Name string
+	Open int
+	Done int
+}
+
+// Names returns every project, context and tag in the list with the
+// number of open and done items of each. Projects go first, then
+// contexts and tags, each sorted by name
+func (l *List) Names() []NameCount {
+	counts := map[string]*NameCount{}
+
+	count := func(sign rune, names []string, done bool) {
+		for _, n := range names {
+			name := string(sign) + n
+
+			c, ok := counts[name]
+			if !ok {
+				c = &NameCount{Name: name}
+				counts[name] = c
+			}
+
+			if done {
+				c.Done++
+			} else {
+				c.Open++
+			}
+		}
+	}
+
+	for _, t := range *l {
+		count(projectSign, t.Projects, t.Done)
+		count(contextSign, t.Contexts, t.Done)
+		count(tagSign, t.Tags, t.Done)
+	}
+
+	order := map[byte]int{projectSign: 0, contextSign: 1, tagSign: 2}
+
+	names := []NameCount{}
+	for _, c := range counts {
+		names = append(names, *c)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		a, b := names[i].Name, names[j].Name
+		if a[0] != b[0] {
+			return order[a[0]] < order[b[0]]
+		}
+		return a < b
+	})
+
+	return names
+}
+
+// find returns the position in the list of the item with the given ID
+func (l *List) find(id int) (int, error) {
+	for i, t := range *l {
+		if t.ID == id {
+			return i, nil
+		}
+	}
+
+	return 0, notExistError(strconv.Itoa(id))
+}
+
+// notExistError is returned for IDs and paths of items not in the list
+type notExistError string
+
+func (e notExistError) Error() string {
+	return fmt.Sprintf("Item %s does not exist", string(e))
+}
+
+// IsNotExist reports whether err is returned for an item not in the list
+func IsNotExist(err error) bool {
+	_, ok := err.(notExistError)
+	return ok
+}
+
+// nextID returns the ID following the highest one in the list
+func (l *List) nextID() int {
+	return l.maxID() + 1
+}
+
+// maxID returns the highest ID in the list, or 0 when it's empty
+func (l *List) maxID() int {
+	max := 0
+	for _, t := range *l {
+		if t.ID > max {
+			max = t.ID
+		}
+	}
+
+	return max
+}
+
+// renumber gives the items added since the List was loaded whose IDs are
+// last or lower, which were given to items deleted since, the IDs
+// following the highest one in the list. Their subtasks follow them
+func (l *List) renumber(last int) {
+	next := l.maxID()
+	if last > next {
+		next = last
+	}
+
+	ls := *l
+	ids := map[int]int{}
+	for i := range ls {
+		if ls[i].unsaved && ls[i].ID <= last {
+			next++
+			ids[ls[i].ID] = next
+			ls[i].ID = next
+		}
+	}
+
+	for i := range ls {
+		if id, ok := ids[ls[i].Parent]; ok {
+			ls[i].Parent = id
+		}
+	}
+}
+
+// migrate assigns IDs to the items saved before items had them. They
+// are numbered in order so each ID matches the old position of the item.
+// Projects, contexts and tags are parsed again from the task text
+func (l *List) migrate() {
+	ls := *l
+	for i := range ls {
+		if ls[i].ID == 0 {
+			ls[i].ID = l.nextID()
+		}
+		ls[i].parseTask()
+	}
+}
+
+// Save method saves the List using the provided file name,
+// in the format given by its extension
 func (l *List) Save(filename string) error {
-	js, err := json.Marshal(l)
+	s, err := NewStore(filename, "")
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(filename, js, 0644)
+	return l.SaveTo(s)
 }
 
 // Get method opens the provided file name, decodes
-// the JSON data and parses it into a List
+// the data in the format given by its extension and parses it
+// into a List. Items saved without an ID get one, kept the
+// next time the List is saved
 func (l *List) Get(filename string) error {
-	file, err := ioutil.ReadFile(filename)
+	s, err := NewStore(filename, "")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return err
+	}
+
+	return l.GetFrom(s)
+}
+
+// SaveTo method saves the List in the Store s. Items added since the
+// List was loaded are numbered after the highest ID the store ever gave,
+// so the IDs of items deleted before aren't given again
+func (l *List) SaveTo(s Store) error {
+	if k, ok := s.(idKeeper); ok {
+		last, err := k.lastID()
+		if err != nil {
+			return err
 		}
+		l.renumber(last)
+	}
+
+	if err := s.Save(*l); err != nil {
 		return err
 	}
 
-	if len(file) == 0 {
-		return nil
+	for i := range *l {
+		(*l)[i].unsaved = false
+	}
+
+	return nil
+}
+
+// GetFrom method replaces the List with the items saved in
+// the Store s
+func (l *List) GetFrom(s Store) error {
+	ls, err := s.Load()
+	if err != nil {
+		return err
 	}
 
-	return json.Unmarshal(file, l)
+	*l = ls
+	l.migrate()
+
+	return nil
 }
@@ -3,7 +3,10 @@ package todo_test
 import (
   "io/ioutil"
   "os"
+  "reflect"
+  "strings"
   "testing"
+  "time"
 
   "pragprog.com/rggo/interacting/todo"
 )
@@ -105,3 +108,368 @@ func TestSaveGet(t *testing.T) {
   }
 
 }
+
+// TestIDs tests that items keep their IDs when others are deleted
+func TestIDs(t *testing.T) {
+  l := todo.List{}
+
+  for _, v := range []string{"New Task 1", "New Task 2", "New Task 3"} {
+    l.Add(v)
+  }
+
+  if err := l.Delete(1); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := l.Complete(3); err != nil {
+    t.Fatal(err)
+  }
+
+  if !l[1].Done || l[1].Task != "New Task 3" {
+    t.Errorf("Expected item 3 completed, got %+v instead.", l[1])
+  }
+
+  if err := l.Delete(1); err == nil {
+    t.Errorf("Expected error deleting item 1 twice.")
+  }
+
+  l.Add("New Task 4")
+
+  if l[2].ID != 4 {
+    t.Errorf("Expected ID %d, got %d instead.", 4, l[2].ID)
+  }
+
+  exp := "  2: New Task 2\nX 3: New Task 3\n  4: New Task 4\n"
+  if l.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, l.String())
+  }
+}
+
+// TestIDsAfterDeletingNewest tests that the ID of the newest item isn't
+// given again after deleting it
+func TestIDsAfterDeletingNewest(t *testing.T) {
+  tf, err := ioutil.TempFile("", "")
+  if err != nil {
+    t.Fatalf("Error creating temp file: %s", err)
+  }
+  tf.Close()
+  defer os.Remove(tf.Name())
+
+  l := todo.List{}
+  for _, v := range []string{"New Task 1", "New Task 2", "New Task 3"} {
+    l.Add(v)
+  }
+
+  if err := l.Save(tf.Name()); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := l.Delete(3); err != nil {
+    t.Fatal(err)
+  }
+
+  // The new item gets its ID when the list is saved
+  l.Add("New Task 4")
+  if err := l.Save(tf.Name()); err != nil {
+    t.Fatal(err)
+  }
+
+  if l[2].ID != 4 {
+    t.Errorf("Expected ID %d, got %d instead.", 4, l[2].ID)
+  }
+}
+
+// TestGetMigratesIDs tests that items saved without IDs get their
+// positions as IDs
+func TestGetMigratesIDs(t *testing.T) {
+  tf, err := ioutil.TempFile("", "")
+  if err != nil {
+    t.Fatalf("Error creating temp file: %s", err)
+  }
+  defer os.Remove(tf.Name())
+
+  old := `[{"Task":"Old Task 1","Done":false},` +
+    `{"Task":"Old Task 2","Done":true}]`
+  if _, err := tf.WriteString(old); err != nil {
+    t.Fatal(err)
+  }
+  tf.Close()
+
+  l := todo.List{}
+  if err := l.Get(tf.Name()); err != nil {
+    t.Fatalf("Error getting list from file: %s", err)
+  }
+
+  for i, item := range l {
+    if item.ID != i+1 {
+      t.Errorf("Expected ID %d, got %d instead.", i+1, item.ID)
+    }
+  }
+
+  l.Add("New Task")
+
+  if l[2].ID != 3 {
+    t.Errorf("Expected ID %d, got %d instead.", 3, l[2].ID)
+  }
+}
+
+// TestSetPriority tests valid and invalid priorities
+func TestSetPriority(t *testing.T) {
+  testCases := []struct {
+    priority string
+    exp      string
+    errMsg   string
+  }{
+    {priority: "a", exp: "A"},
+    {priority: "Z", exp: "Z"},
+    {priority: "3", exp: "3"},
+    {priority: "", exp: ""},
+    {priority: "6", errMsg: "Invalid priority"},
+    {priority: "AB", errMsg: "Invalid priority"},
+  }
+
+  for _, tc := range testCases {
+    l := todo.List{}
+    l.Add("New Task")
+
+    err := l.SetPriority(1, tc.priority)
+
+    if tc.errMsg != "" {
+      if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+        t.Errorf("Expected error %q, got %v instead.", tc.errMsg, err)
+      }
+      continue
+    }
+
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if l[0].Priority != tc.exp {
+      t.Errorf("Expected %q, got %q instead.", tc.exp, l[0].Priority)
+    }
+  }
+}
+
+// newDueList returns a list with items due on different days from now
+func newDueList(t *testing.T, now time.Time) todo.List {
+  t.Helper()
+
+  l := todo.List{}
+  tasks := []struct {
+    task     string
+    priority string
+    days     int
+  }{
+    {"Late", "C", -2},
+    {"No date", "", 0},
+    {"Today", "1", 0},
+    {"Next week", "A", 10},
+    {"In 3 days", "", 3},
+  }
+
+  for _, v := range tasks {
+    l.Add(v.task)
+    id := l[len(l)-1].ID
+
+    if err := l.SetPriority(id, v.priority); err != nil {
+      t.Fatal(err)
+    }
+
+    if v.task != "No date" {
+      if err := l.SetDue(id, now.AddDate(0, 0, v.days)); err != nil {
+        t.Fatal(err)
+      }
+    }
+  }
+
+  return l
+}
+
+// TestSort tests sorting by priority and due date
+func TestSort(t *testing.T) {
+  now := time.Now()
+
+  testCases := []struct {
+    by  string
+    exp []string
+  }{
+    {by: "priority", exp: []string{"Today", "Next week", "Late", "No date",
+      "In 3 days"}},
+    {by: "due", exp: []string{"Late", "Today", "In 3 days", "Next week",
+      "No date"}},
+  }
+
+  for _, tc := range testCases {
+    l := newDueList(t, now)
+
+    if err := l.Sort(tc.by); err != nil {
+      t.Fatal(err)
+    }
+
+    for i, task := range tc.exp {
+      if l[i].Task != task {
+        t.Errorf("Sorting by %s expected %q at %d, got %q instead.", tc.by,
+          task, i, l[i].Task)
+      }
+    }
+  }
+
+  l := newDueList(t, now)
+  if err := l.Sort("name"); err == nil {
+    t.Errorf("Expected error sorting by name.")
+  }
+}
+
+// TestFilterDue tests the items due today, this week and overdue
+func TestFilterDue(t *testing.T) {
+  now := time.Now()
+
+  testCases := []struct {
+    when string
+    exp  []string
+  }{
+    {when: "today", exp: []string{"Today"}},
+    {when: "week", exp: []string{"Today", "In 3 days"}},
+    {when: "overdue", exp: []string{"Late"}},
+  }
+
+  for _, tc := range testCases {
+    l := newDueList(t, now)
+
+    // Completed items are never due
+    l.Add("Done today")
+    id := l[len(l)-1].ID
+    l.SetDue(id, now)
+    l.Complete(id)
+
+    filtered, err := l.FilterDue(tc.when, now)
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    if len(filtered) != len(tc.exp) {
+      t.Fatalf("Filtering %s expected %d items, got %d instead.", tc.when,
+        len(tc.exp), len(filtered))
+    }
+
+    for i, task := range tc.exp {
+      if filtered[i].Task != task {
+        t.Errorf("Filtering %s expected %q, got %q instead.", tc.when, task,
+          filtered[i].Task)
+      }
+    }
+  }
+}
+
+// TestStringPriorityDue tests printing priorities, due dates and
+// highlighting overdue items
+func TestStringPriorityDue(t *testing.T) {
+  now := time.Now()
+  l := newDueList(t, now)
+
+  date := func(days int) string {
+    return now.AddDate(0, 0, days).Format(todo.DateFormat)
+  }
+
+  exp := "! 1: (C) Late (due " + date(-2) + ")\n" +
+    "  2: No date\n" +
+    "  3: (1) Today (due " + date(0) + ")\n" +
+    "  4: (A) Next week (due " + date(10) + ")\n" +
+    "  5: In 3 days (due " + date(3) + ")\n"
+
+  if l.String() != exp {
+    t.Errorf("Expected %q, got %q instead.", exp, l.String())
+  }
+}
+
+// newProjectList returns a list with items of several projects
+func newProjectList() todo.List {
+  l := todo.List{}
+
+  for _, v := range []string{
+    "Write report +work @office #q3 +work",
+    "Call the bank @phone",
+    "Plan trip +home @phone #summer",
+    "Fix printer +work @office",
+  } {
+    l.Add(v)
+  }
+  l.Complete(4)
+
+  return l
+}
+
+// TestParseTask tests the projects, contexts and tags found in tasks
+func TestParseTask(t *testing.T) {
+  l := newProjectList()
+
+  if !reflect.DeepEqual(l[0].Projects, []string{"work"}) {
+    t.Errorf("Expected projects %q, got %q instead.", []string{"work"},
+      l[0].Projects)
+  }
+
+  if !reflect.DeepEqual(l[0].Contexts, []string{"office"}) {
+    t.Errorf("Expected contexts %q, got %q instead.", []string{"office"},
+      l[0].Contexts)
+  }
+
+  if !reflect.DeepEqual(l[0].Tags, []string{"q3"}) {
+    t.Errorf("Expected tags %q, got %q instead.", []string{"q3"}, l[0].Tags)
+  }
+
+  if l[1].Projects != nil || l[1].Tags != nil {
+    t.Errorf("Expected no projects or tags, got %+v instead.", l[1])
+  }
+}
+
+// TestFilterBy tests filtering items by project, context and tag
+func TestFilterBy(t *testing.T) {
+  testCases := []struct {
+    name    string
+    project string
+    context string
+    tag     string
+    exp     []int
+  }{
+    {name: "All", exp: []int{1, 2, 3, 4}},
+    {name: "Project", project: "work", exp: []int{1, 4}},
+    {name: "ProjectSign", project: "+home", exp: []int{3}},
+    {name: "Context", context: "@phone", exp: []int{2, 3}},
+    {name: "Tag", tag: "q3", exp: []int{1}},
+    {name: "Combined", project: "work", context: "phone", exp: []int{}},
+  }
+
+  for _, tc := range testCases {
+    t.Run(tc.name, func(t *testing.T) {
+      l := newProjectList()
+
+      ids := []int{}
+      for _, item := range l.FilterBy(tc.project, tc.context, tc.tag) {
+        ids = append(ids, item.ID)
+      }
+
+      if !reflect.DeepEqual(ids, tc.exp) {
+        t.Errorf("Expected items %v, got %v instead.", tc.exp, ids)
+      }
+    })
+  }
+}
+
+// TestNames tests counting the open and done items of every name
+func TestNames(t *testing.T) {
+  l := newProjectList()
+
+  exp := []todo.NameCount{
+    {Name: "+home", Open: 1},
+    {Name: "+work", Open: 1, Done: 1},
+    {Name: "@office", Open: 1, Done: 1},
+    {Name: "@phone", Open: 2},
+    {Name: "#q3", Open: 1},
+    {Name: "#summer", Open: 1},
+  }
+
+  if res := l.Names(); !reflect.DeepEqual(res, exp) {
+    t.Errorf("Expected %+v, got %+v instead.", exp, res)
+  }
+}
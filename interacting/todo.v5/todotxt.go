@@ -0,0 +1,200 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todoTxtStore saves the List in the todo.txt format, one item per line,
+// so it can be shared with other todo.txt tools:
+//
+//	x 2020-04-20 2020-04-18 Completed task +project @context id:1
+//	(A) 2020-04-18 Task with priority due:2020-04-30 id:2
+//
+// The format keeps only the dates the items were created and completed.
+// Priorities of completed items and numeric priorities, which todo.txt
+// doesn't support, are saved as pri:X, recurrence rules as rec:rule and
+// subtasks as parent:ID sub:N. The highest ID given is saved in a first
+// line like "# last_id: 9" when the newest items were deleted
+type todoTxtStore struct {
+	filename string
+}
+
+func (s *todoTxtStore) Load() (List, error) {
+	l, _, err := s.read()
+	return l, err
+}
+
+func (s *todoTxtStore) Save(l List) error {
+	last, err := s.lastID()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(formatLastID(l, last))
+
+	for _, t := range l {
+		buf.WriteString(formatTodoTxt(t))
+		buf.WriteByte('\n')
+	}
+
+	return writeFile(s.filename, buf.Bytes())
+}
+
+func (s *todoTxtStore) lastID() (int, error) {
+	l, last, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+
+	if max := l.maxID(); max > last {
+		last = max
+	}
+
+	return last, nil
+}
+
+// read returns the items in the file and the highest ID given saved with
+// them
+func (s *todoTxtStore) read() (List, int, error) {
+	l := List{}
+
+	file, err := readFile(s.filename)
+	if err != nil {
+		return l, 0, err
+	}
+
+	last, file := parseLastID(file)
+
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		l = append(l, parseTodoTxt(line))
+	}
+
+	return l, last, scanner.Err()
+}
+
+// formatTodoTxt returns the item t as a line in the todo.txt format
+func formatTodoTxt(t item) string {
+	fields := []string{}
+	pri := ""
+
+	switch {
+	case t.Done:
+		fields = append(fields, "x", t.CompletedAt.Format(DateFormat))
+		pri = t.Priority
+	case len(t.Priority) == 1 && t.Priority[0] >= 'A' && t.Priority[0] <= 'Z':
+		fields = append(fields, "("+t.Priority+")")
+	default:
+		pri = t.Priority
+	}
+
+	if !t.CreatedAt.IsZero() {
+		fields = append(fields, t.CreatedAt.Format(DateFormat))
+	}
+
+	fields = append(fields, t.Task)
+
+	if !t.Due.IsZero() {
+		fields = append(fields, "due:"+t.Due.Format(DateFormat))
+	}
+	if pri != "" {
+		fields = append(fields, "pri:"+pri)
+	}
+	if t.Recur != "" {
+		fields = append(fields, "rec:"+t.Recur)
+	}
+	if t.Parent != 0 {
+		fields = append(fields, "parent:"+strconv.Itoa(t.Parent),
+			"sub:"+strconv.Itoa(t.Sub))
+	}
+	fields = append(fields, "id:"+strconv.Itoa(t.ID))
+
+	return strings.Join(fields, " ")
+}
+
+// parseTodoTxt returns the item saved in a line in the todo.txt format
+func parseTodoTxt(line string) item {
+	t := item{}
+	words := strings.Fields(line)
+
+	// date parses the first word as a date, removing it when it is one
+	date := func() time.Time {
+		if len(words) == 0 {
+			return time.Time{}
+		}
+
+		d, err := time.ParseInLocation(DateFormat, words[0], time.Local)
+		if err != nil {
+			return time.Time{}
+		}
+
+		words = words[1:]
+		return d
+	}
+
+	switch w := words[0]; {
+	case w == "x":
+		t.Done = true
+		words = words[1:]
+		t.CompletedAt = date()
+	case len(w) == 3 && w[0] == '(' && w[2] == ')' && w[1] >= 'A' &&
+		w[1] <= 'Z':
+		t.Priority = w[1:2]
+		words = words[1:]
+	}
+
+	t.CreatedAt = date()
+
+	// Values that don't parse are part of the task text
+	task := []string{}
+	for _, w := range words {
+		switch {
+		case strings.HasPrefix(w, "id:"):
+			if id, err := strconv.Atoi(w[len("id:"):]); err == nil {
+				t.ID = id
+				continue
+			}
+		case strings.HasPrefix(w, "due:"):
+			due, err := time.ParseInLocation(DateFormat, w[len("due:"):],
+				time.Local)
+			if err == nil {
+				t.Due = due
+				continue
+			}
+		case strings.HasPrefix(w, "pri:") && len(w) > len("pri:"):
+			t.Priority = w[len("pri:"):]
+			continue
+		case strings.HasPrefix(w, "parent:"):
+			if id, err := strconv.Atoi(w[len("parent:"):]); err == nil {
+				t.Parent = id
+				continue
+			}
+		case strings.HasPrefix(w, "sub:"):
+			if sub, err := strconv.Atoi(w[len("sub:"):]); err == nil {
+				t.Sub = sub
+				continue
+			}
+		case strings.HasPrefix(w, "rec:"):
+			if _, err := parseRule(w[len("rec:"):]); err == nil {
+				t.Recur = w[len("rec:"):]
+				continue
+			}
+		}
+
+		task = append(task, w)
+	}
+
+	t.Task = strings.Join(task, " ")
+
+	return t
+}